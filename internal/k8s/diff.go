@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// FieldDiff - difference of a single field between two versions of the resource
+type FieldDiff struct {
+	// Field path, i.e. containers[app].image or metadata.labels[app]
+	Field string `json:"field"`
+	// Manifest value, empty when field is missing in the manifest
+	Manifest string `json:"manifest"`
+	// Live value, empty when field is missing in the live resource
+	Live string `json:"live"`
+}
+
+// DiffFromManifest - reports how the resource drifted from the manifest (i.e. last
+// applied manifest stored in Git). Container images, labels and annotations are
+// compared. Manifest has to be of the same kind as the resource.
+func (r *GenericResource) DiffFromManifest(manifest []byte) ([]FieldDiff, error) {
+	desired, err := NewGenericResourceFromYAML(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if desired.Kind() != r.Kind() {
+		return nil, fmt.Errorf("manifest kind %s doesn't match resource kind %s", desired.Kind(), r.Kind())
+	}
+
+	diffs := []FieldDiff{}
+	diffs = append(diffs, diffContainerImages("containers", desired.Containers(), r.Containers())...)
+	diffs = append(diffs, diffContainerImages("initContainers", desired.InitContainers(), r.InitContainers())...)
+	diffs = append(diffs, diffMaps("metadata.labels", desired.GetLabels(), r.GetLabels())...)
+	diffs = append(diffs, diffMaps("metadata.annotations", desired.GetAnnotations(), r.GetAnnotations())...)
+
+	return diffs, nil
+}
+
+func diffContainerImages(field string, manifest, live []core_v1.Container) []FieldDiff {
+	manifestImages := make(map[string]string)
+	for _, c := range manifest {
+		manifestImages[c.Name] = c.Image
+	}
+	liveImages := make(map[string]string)
+	for _, c := range live {
+		liveImages[c.Name] = c.Image
+	}
+
+	var diffs []FieldDiff
+	for _, d := range diffMaps(field, manifestImages, liveImages) {
+		d.Field += ".image"
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+func diffMaps(field string, manifest, live map[string]string) []FieldDiff {
+	keys := make(map[string]bool)
+	for k := range manifest {
+		keys[k] = true
+	}
+	for k := range live {
+		keys[k] = true
+	}
+
+	var diffs []FieldDiff
+	for k := range keys {
+		manifestValue, inManifest := manifest[k]
+		liveValue, inLive := live[k]
+		if inManifest == inLive && manifestValue == liveValue {
+			continue
+		}
+		diffs = append(diffs, FieldDiff{
+			Field:    fmt.Sprintf("%s[%s]", field, k),
+			Manifest: manifestValue,
+			Live:     liveValue,
+		})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs
+}
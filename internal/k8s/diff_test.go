@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFromManifest(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wd
+  namespace: default
+  labels:
+    app: wd
+  annotations:
+    keel.sh/policy: major
+spec:
+  template:
+    spec:
+      containers:
+      - name: wd
+        image: keelhq/push-workflow-example:0.1.0
+      - name: sidecar
+        image: keelhq/sidecar:1.0.0
+`
+	gr, err := NewGenericResourceFromYAML([]byte(manifest))
+	if err != nil {
+		t.Fatalf("failed to decode manifest: %s", err)
+	}
+
+	diffs, err := gr.DiffFromManifest([]byte(manifest))
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("expected no differences, got: %v", diffs)
+	}
+
+	gr.UpdateContainer(0, "keelhq/push-workflow-example:0.2.0")
+	gr.SetLabels(map[string]string{"app": "wd", "team": "payments"})
+	gr.SetAnnotations(map[string]string{})
+
+	diffs, err = gr.DiffFromManifest([]byte(manifest))
+	if err != nil {
+		t.Fatalf("failed to diff: %s", err)
+	}
+
+	expected := []FieldDiff{
+		{Field: "containers[wd].image", Manifest: "keelhq/push-workflow-example:0.1.0", Live: "keelhq/push-workflow-example:0.2.0"},
+		{Field: "metadata.labels[team]", Manifest: "", Live: "payments"},
+		{Field: "metadata.annotations[keel.sh/policy]", Manifest: "major", Live: ""},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("unexpected diffs: %+v", diffs)
+	}
+
+	cronjob := `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: wd
+  namespace: default
+`
+	if _, err := gr.DiffFromManifest([]byte(cronjob)); err == nil {
+		t.Errorf("expected an error for a manifest of a different kind")
+	}
+}
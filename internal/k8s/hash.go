@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/keel-hq/keel/types"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+// HashPodTemplate - returns hash of the whole pod template, including annotations
+// set by Keel itself
+func (r *GenericResource) HashPodTemplate() (string, error) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return "", ErrUnsupportedResource
+	}
+	return hashPodTemplate(template)
+}
+
+// rolloutAnnotations - pod template annotations outside of keel.sh/* which are set
+// on rollouts (Touch, SetChangeCause) rather than by changing the spec
+var rolloutAnnotations = []string{
+	types.KubernetesRestartedAtAnnotation,
+	types.KubernetesChangeCauseAnnotation,
+}
+
+// StableSpecHash - returns hash of the pod template with keel.sh/* and rollout
+// (kubectl.kubernetes.io/restartedAt, kubernetes.io/change-cause) annotations
+// stripped, so it only changes when the spec is changed by someone else than Keel
+// (i.e. update time stamped on every rollout is ignored)
+func (r *GenericResource) StableSpecHash() (string, error) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return "", ErrUnsupportedResource
+	}
+
+	stripped := template.DeepCopy()
+	for k := range stripped.Annotations {
+		if strings.HasPrefix(k, types.KeelAnnotationPrefix) {
+			delete(stripped.Annotations, k)
+		}
+	}
+	for _, k := range rolloutAnnotations {
+		delete(stripped.Annotations, k)
+	}
+	return hashPodTemplate(stripped)
+}
+
+func hashPodTemplate(template *core_v1.PodTemplateSpec) (string, error) {
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode pod template: %s", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStableSpecHash(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Annotations: map[string]string{"prometheus.io/scrape": "true"},
+		},
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		stable, err := gr.StableSpecHash()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		raw, err := gr.HashPodTemplate()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+
+		gr.StampUpdateTime(time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC))
+
+		if got, _ := gr.StableSpecHash(); got != stable {
+			t.Errorf("%s: stable hash must ignore keel annotations", gr.Kind())
+		}
+		if got, _ := gr.HashPodTemplate(); got == raw {
+			t.Errorf("%s: raw hash must include keel annotations", gr.Kind())
+		}
+
+		gr.Touch(time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC))
+		gr.SetChangeCause("keel automated update, version 1.1.1 -> 1.1.2")
+		if got, _ := gr.StableSpecHash(); got != stable {
+			t.Errorf("%s: stable hash must ignore rollout annotations", gr.Kind())
+		}
+
+		gr.getPodTemplate().Annotations["prometheus.io/port"] = "8080"
+		if got, _ := gr.StableSpecHash(); got == stable {
+			t.Errorf("%s: stable hash must change on other annotations", gr.Kind())
+		}
+	}
+}
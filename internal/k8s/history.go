@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/keel-hq/keel/types"
+)
+
+// MaxImageHistory - number of entries kept in the image history annotation
+const MaxImageHistory = 10
+
+// HistoryEntry - single image change
+type HistoryEntry struct {
+	Container string    `json:"container"`
+	OldImage  string    `json:"oldImage"`
+	NewImage  string    `json:"newImage"`
+	Time      time.Time `json:"time"`
+}
+
+// AppendImageHistory - records image change in the keel.sh/history annotation, only
+// the last MaxImageHistory entries are kept. Unreadable history is discarded.
+func (r *GenericResource) AppendImageHistory(container, oldImage, newImage string, t time.Time) {
+	history, err := r.GetImageHistory()
+	if err != nil {
+		history = nil
+	}
+
+	history = append(history, HistoryEntry{
+		Container: container,
+		OldImage:  oldImage,
+		NewImage:  newImage,
+		Time:      t.UTC(),
+	})
+	if len(history) > MaxImageHistory {
+		history = history[len(history)-MaxImageHistory:]
+	}
+
+	encoded, err := json.Marshal(history)
+	if err != nil {
+		return
+	}
+
+	annotations := r.GetAnnotations()
+	annotations[types.KeelHistoryAnnotation] = string(encoded)
+	r.SetAnnotations(annotations)
+}
+
+// GetImageHistory - returns recorded image changes, oldest first
+func (r *GenericResource) GetImageHistory() ([]HistoryEntry, error) {
+	value, ok := r.GetAnnotations()[types.KeelHistoryAnnotation]
+	if !ok || value == "" {
+		return []HistoryEntry{}, nil
+	}
+
+	var history []HistoryEntry
+	if err := json.Unmarshal([]byte(value), &history); err != nil {
+		return nil, fmt.Errorf("failed to decode image history: %s", err)
+	}
+	return history, nil
+}
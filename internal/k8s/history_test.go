@@ -0,0 +1,46 @@
+package k8s
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/keel-hq/keel/types"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestImageHistory(t *testing.T) {
+	now := time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		history, err := gr.GetImageHistory()
+		if err != nil || len(history) != 0 {
+			t.Errorf("%s: unexpected history: %v, %v", gr.Kind(), history, err)
+		}
+
+		for i := 0; i < MaxImageHistory+2; i++ {
+			gr.AppendImageHistory("app", fmt.Sprintf("app:1.0.%d", i), fmt.Sprintf("app:1.0.%d", i+1), now.Add(time.Duration(i)*time.Minute))
+		}
+
+		history, err = gr.GetImageHistory()
+		if err != nil {
+			t.Fatalf("%s: failed to get history: %s", gr.Kind(), err)
+		}
+		if len(history) != MaxImageHistory {
+			t.Fatalf("%s: unexpected history length: %d", gr.Kind(), len(history))
+		}
+		last := history[len(history)-1]
+		if last.OldImage != "app:1.0.11" || last.NewImage != "app:1.0.12" || !last.Time.Equal(now.Add(11*time.Minute)) {
+			t.Errorf("%s: unexpected last entry: %+v", gr.Kind(), last)
+		}
+		if history[0].OldImage != "app:1.0.2" {
+			t.Errorf("%s: unexpected first entry: %+v", gr.Kind(), history[0])
+		}
+
+		gr.SetAnnotations(map[string]string{types.KeelHistoryAnnotation: "not json"})
+		if _, err := gr.GetImageHistory(); err == nil {
+			t.Errorf("%s: expected an error for corrupted history", gr.Kind())
+		}
+	}
+}
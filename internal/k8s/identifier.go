@@ -0,0 +1,36 @@
+package k8s
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResourceKind - kind of the resource as used in identifiers (i.e. "deployment")
+type ResourceKind string
+
+// available resource kinds, values match GenericResource.Kind()
+const (
+	KindDeployment  ResourceKind = "deployment"
+	KindStatefulSet ResourceKind = "statefulset"
+	KindDaemonSet   ResourceKind = "daemonset"
+	KindCronJob     ResourceKind = "cronjob"
+)
+
+// ParseIdentifier - reverses GenericResource.Identifier, identifiers are built by
+// the get<Kind>Identifier helpers as "<kind>/<namespace>/<name>", i.e.
+// "deployment/default/web". Unknown kinds and malformed identifiers are rejected.
+func ParseIdentifier(id string) (kind ResourceKind, namespace, name string, err error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid identifier %q, expected <kind>/<namespace>/<name>", id)
+	}
+
+	kind = ResourceKind(parts[0])
+	switch kind {
+	case KindDeployment, KindStatefulSet, KindDaemonSet, KindCronJob:
+	default:
+		return "", "", "", fmt.Errorf("invalid identifier %q: %w: %s", id, ErrUnsupportedResource, parts[0])
+	}
+
+	return kind, parts[1], parts[2], nil
+}
@@ -0,0 +1,39 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		kind, namespace, name, err := ParseIdentifier(gr.Identifier)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if string(kind) != gr.Kind() || namespace != gr.Namespace || name != gr.Name {
+			t.Errorf("%s: unexpected result: %s/%s/%s", gr.Kind(), kind, namespace, name)
+		}
+	}
+}
+
+func TestParseIdentifierInvalid(t *testing.T) {
+	for _, id := range []string{
+		"",
+		"deployment",
+		"deployment/default",
+		"deployment//web",
+		"deployment/default/",
+		"deployment/default/web/extra",
+	} {
+		if _, _, _, err := ParseIdentifier(id); err == nil {
+			t.Errorf("expected error for %q", id)
+		}
+	}
+
+	if _, _, _, err := ParseIdentifier("pod/default/web"); !errors.Is(err, ErrUnsupportedResource) {
+		t.Errorf("expected unsupported resource error, got %v", err)
+	}
+}
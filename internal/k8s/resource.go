@@ -1,15 +1,44 @@
 package k8s
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/keel-hq/keel/internal/policy"
+	"github.com/keel-hq/keel/types"
+	"github.com/keel-hq/keel/util/image"
+	"github.com/ryanuber/go-glob"
 
 	apps_v1 "k8s.io/api/apps/v1"
 	batch_v1 "k8s.io/api/batch/v1"
 	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8s_types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
 )
 
+// ErrUnsupportedResource - returned when Keel doesn't handle the kind of the resource
+var ErrUnsupportedResource = errors.New("unsupported resource type")
+
+// ErrContainerIndexOutOfRange - returned when container index doesn't exist
+var ErrContainerIndexOutOfRange = errors.New("container index out of range")
+
+// ErrContainerNotFound - returned when named container doesn't exist
+var ErrContainerNotFound = errors.New("container not found")
+
+// ErrTagNotSemver - returned alongside the result when tags had to be compared as strings
+var ErrTagNotSemver = errors.New("tag is not a semantic version")
+
 // GenericResource - generic resource,
 // used to work with multiple kinds of k8s resources
 type GenericResource struct {
@@ -19,6 +48,10 @@ type GenericResource struct {
 	Identifier string
 	Namespace  string
 	Name       string
+
+	// images when the resource was read, RebaseOnto re-applies only
+	// the images changed since
+	read []ContainerImage
 }
 
 type genericResource []*GenericResource
@@ -44,7 +77,7 @@ func NewGenericResource(obj interface{}) (*GenericResource, error) {
 	case *batch_v1.CronJob:
 		// ok
 	default:
-		return nil, fmt.Errorf("unsupported resource type: %v", reflect.TypeOf(obj).Kind())
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedResource, reflect.TypeOf(obj).Kind())
 	}
 
 	gr := &GenericResource{
@@ -54,10 +87,24 @@ func NewGenericResource(obj interface{}) (*GenericResource, error) {
 	gr.Identifier = gr.GetIdentifier()
 	gr.Namespace = gr.GetNamespace()
 	gr.Name = gr.GetName()
+	gr.read = gr.ImageInventory()
 
 	return gr, nil
 }
 
+// NewGenericResourceFromYAML - decodes a YAML (or JSON) manifest and creates
+// new generic k8s resource from it
+func NewGenericResourceFromYAML(data []byte) (*GenericResource, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedResource, err)
+		}
+		return nil, fmt.Errorf("failed to decode manifest: %s", err)
+	}
+	return NewGenericResource(obj)
+}
+
 func (r *GenericResource) String() string {
 	return fmt.Sprintf("%s/%s/%s images: %s", r.Kind(), r.Namespace, r.Name, strings.Join(r.GetImages(), ", "))
 }
@@ -71,6 +118,7 @@ func (r *GenericResource) DeepCopy() *GenericResource {
 	gr.Identifier = r.Identifier
 	gr.Namespace = r.Namespace
 	gr.Name = r.Name
+	gr.read = append([]ContainerImage(nil), r.read...)
 
 	switch obj := r.obj.(type) {
 	case *apps_v1.Deployment:
@@ -146,11 +194,68 @@ func (r *GenericResource) Kind() string {
 	return ""
 }
 
+// GVK returns group, version and kind of the resource
+func (r *GenericResource) GVK() schema.GroupVersionKind {
+	switch r.obj.(type) {
+	case *apps_v1.Deployment:
+		return apps_v1.SchemeGroupVersion.WithKind("Deployment")
+	case *apps_v1.StatefulSet:
+		return apps_v1.SchemeGroupVersion.WithKind("StatefulSet")
+	case *apps_v1.DaemonSet:
+		return apps_v1.SchemeGroupVersion.WithKind("DaemonSet")
+	case *batch_v1.CronJob:
+		return batch_v1.SchemeGroupVersion.WithKind("CronJob")
+	}
+	return schema.GroupVersionKind{}
+}
+
+// IsDeployment - returns true when resource is a deployment
+func (r *GenericResource) IsDeployment() bool {
+	switch r.obj.(type) {
+	case *apps_v1.Deployment:
+		return true
+	}
+	return false
+}
+
+// IsStatefulSet - returns true when resource is a statefulset
+func (r *GenericResource) IsStatefulSet() bool {
+	switch r.obj.(type) {
+	case *apps_v1.StatefulSet:
+		return true
+	}
+	return false
+}
+
+// IsDaemonSet - returns true when resource is a daemonset
+func (r *GenericResource) IsDaemonSet() bool {
+	switch r.obj.(type) {
+	case *apps_v1.DaemonSet:
+		return true
+	}
+	return false
+}
+
+// IsCronJob - returns true when resource is a cronjob
+func (r *GenericResource) IsCronJob() bool {
+	switch r.obj.(type) {
+	case *batch_v1.CronJob:
+		return true
+	}
+	return false
+}
+
 // GetResource - get resource
 func (r *GenericResource) GetResource() interface{} {
 	return r.obj
 }
 
+// RuntimeObject - returns resource as a runtime object, false when it's not set
+func (r *GenericResource) RuntimeObject() (runtime.Object, bool) {
+	obj, ok := r.obj.(runtime.Object)
+	return obj, ok
+}
+
 // GetLabels - get resource labels
 func (r *GenericResource) GetLabels() (labels map[string]string) {
 	switch obj := r.obj.(type) {
@@ -180,6 +285,15 @@ func (r *GenericResource) SetLabels(labels map[string]string) {
 	}
 }
 
+// MergeLabels - adds or overwrites provided labels, other labels are left intact
+func (r *GenericResource) MergeLabels(add map[string]string) {
+	labels := r.GetLabels()
+	for k, v := range add {
+		labels[k] = v
+	}
+	r.SetLabels(labels)
+}
+
 // GetSpecAnnotations - get resource spec template annotations
 func (r *GenericResource) GetSpecAnnotations() (annotations map[string]string) {
 	switch obj := r.obj.(type) {
@@ -245,6 +359,15 @@ func (r *GenericResource) SetAnnotations(annotations map[string]string) {
 	}
 }
 
+// MergeAnnotations - adds or overwrites provided annotations, other annotations are left intact
+func (r *GenericResource) MergeAnnotations(add map[string]string) {
+	annotations := r.GetAnnotations()
+	for k, v := range add {
+		annotations[k] = v
+	}
+	r.SetAnnotations(annotations)
+}
+
 // GetImagePullSecrets - returns secrets from pod spec
 func (r *GenericResource) GetImagePullSecrets() (secrets []string) {
 	switch obj := r.obj.(type) {
@@ -260,6 +383,33 @@ func (r *GenericResource) GetImagePullSecrets() (secrets []string) {
 	return
 }
 
+// SetImagePullSecrets - replaces image pull secrets of the pod spec
+func (r *GenericResource) SetImagePullSecrets(names []string) {
+	podSpec := r.getPodSpec()
+	if podSpec == nil {
+		return
+	}
+	var secrets []core_v1.LocalObjectReference
+	for _, name := range names {
+		secrets = append(secrets, core_v1.LocalObjectReference{Name: name})
+	}
+	podSpec.ImagePullSecrets = secrets
+}
+
+// AddImagePullSecret - adds image pull secret to the pod spec unless it's already there
+func (r *GenericResource) AddImagePullSecret(name string) {
+	podSpec := r.getPodSpec()
+	if podSpec == nil {
+		return
+	}
+	for _, s := range podSpec.ImagePullSecrets {
+		if s.Name == name {
+			return
+		}
+	}
+	podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, core_v1.LocalObjectReference{Name: name})
+}
+
 // GetImages - returns images used by this resource
 func (r *GenericResource) GetImages() (images []string) {
 	switch obj := r.obj.(type) {
@@ -320,6 +470,89 @@ func (r *GenericResource) InitContainers() (containers []core_v1.Container) {
 	return
 }
 
+// getObjectMeta - returns a pointer to the resource metadata
+func (r *GenericResource) getObjectMeta() *meta_v1.ObjectMeta {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return &obj.ObjectMeta
+	case *apps_v1.StatefulSet:
+		return &obj.ObjectMeta
+	case *apps_v1.DaemonSet:
+		return &obj.ObjectMeta
+	case *batch_v1.CronJob:
+		return &obj.ObjectMeta
+	}
+	return nil
+}
+
+// getPodTemplate - returns a pointer to the pod template of the resource,
+// for cronjobs it's the pod template of the job template
+func (r *GenericResource) getPodTemplate() *core_v1.PodTemplateSpec {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return &obj.Spec.Template
+	case *apps_v1.StatefulSet:
+		return &obj.Spec.Template
+	case *apps_v1.DaemonSet:
+		return &obj.Spec.Template
+	case *batch_v1.CronJob:
+		return &obj.Spec.JobTemplate.Spec.Template
+	}
+	return nil
+}
+
+// getPodSpec - returns a pointer to the pod spec of the resource template
+func (r *GenericResource) getPodSpec() *core_v1.PodSpec {
+	template := r.getPodTemplate()
+	if template == nil {
+		return nil
+	}
+	return &template.Spec
+}
+
+// allContainers - returns regular containers followed by init containers
+func (r *GenericResource) allContainers() []core_v1.Container {
+	var containers []core_v1.Container
+	containers = append(containers, r.Containers()...)
+	containers = append(containers, r.InitContainers()...)
+	return containers
+}
+
+// getContainer - returns a pointer to the named container, regular containers
+// are checked first, then init containers
+func (r *GenericResource) getContainer(name string) (*core_v1.Container, bool) {
+	if strings.HasPrefix(name, initContainerNamePrefix) {
+		initContainers := r.InitContainers()
+		for i := range initContainers {
+			if initContainerNamePrefix+initContainers[i].Name == name {
+				return &initContainers[i], true
+			}
+		}
+		return nil, false
+	}
+	containers := r.Containers()
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i], true
+		}
+	}
+	initContainers := r.InitContainers()
+	for i := range initContainers {
+		if initContainers[i].Name == name {
+			return &initContainers[i], true
+		}
+	}
+	return nil, false
+}
+
+// checkContainerIndex - returns an error when resource has no container at the index
+func (r *GenericResource) checkContainerIndex(index int) error {
+	if index < 0 || index >= len(r.Containers()) {
+		return fmt.Errorf("%w: %d, resource has %d container(s)", ErrContainerIndexOutOfRange, index, len(r.Containers()))
+	}
+	return nil
+}
+
 // UpdateContainer - updates container image
 func (r *GenericResource) UpdateContainer(index int, image string) {
 	switch obj := r.obj.(type) {
@@ -409,3 +642,2008 @@ func (r *GenericResource) GetStatus() Status {
 	}
 	return Status{}
 }
+
+// GetActiveDeadlineSeconds - returns the job active deadline, only applicable
+// to kinds that run jobs (cronjobs), nil for others
+func (r *GenericResource) GetActiveDeadlineSeconds() *int64 {
+	switch obj := r.obj.(type) {
+	case *batch_v1.CronJob:
+		return obj.Spec.JobTemplate.Spec.ActiveDeadlineSeconds
+	}
+	return nil
+}
+
+// MergePatchFrom - computes a strategic merge patch that turns original
+// into the receiver, both resources must be of the same kind
+func (r *GenericResource) MergePatchFrom(original *GenericResource) ([]byte, k8s_types.PatchType, error) {
+	if original == nil || original.Kind() != r.Kind() {
+		return nil, "", fmt.Errorf("cannot create patch between different kinds of resources")
+	}
+
+	var dataStruct interface{}
+	switch r.obj.(type) {
+	case *apps_v1.Deployment:
+		dataStruct = apps_v1.Deployment{}
+	case *apps_v1.StatefulSet:
+		dataStruct = apps_v1.StatefulSet{}
+	case *apps_v1.DaemonSet:
+		dataStruct = apps_v1.DaemonSet{}
+	case *batch_v1.CronJob:
+		dataStruct = batch_v1.CronJob{}
+	default:
+		return nil, "", fmt.Errorf("unsupported resource type: %T", r.obj)
+	}
+
+	originalJSON, err := json.Marshal(original.obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal original resource: %s", err)
+	}
+
+	modifiedJSON, err := json.Marshal(r.obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal modified resource: %s", err)
+	}
+
+	patch, err := strategicpatch.CreateTwoWayMergePatch(originalJSON, modifiedJSON, dataStruct)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create merge patch: %s", err)
+	}
+
+	return patch, k8s_types.StrategicMergePatchType, nil
+}
+
+// GetImageID - returns image reference of the named container.
+// Note: running image digests are only reported in pod statuses
+// (Status.ContainerStatuses[].ImageID) which workloads don't carry, so for now
+// this returns the image from the spec. It's the hook point for pod status
+// integration later on.
+func (r *GenericResource) GetImageID(containerName string) (string, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return "", false
+	}
+	return c.Image, true
+}
+
+// GetLabelsWithPrefix - returns resource labels which keys start with the prefix
+func (r *GenericResource) GetLabelsWithPrefix(prefix string) map[string]string {
+	return filterByPrefix(r.GetLabels(), prefix)
+}
+
+// GetAnnotationsWithPrefix - returns resource annotations which keys start with the prefix
+func (r *GenericResource) GetAnnotationsWithPrefix(prefix string) map[string]string {
+	return filterByPrefix(r.GetAnnotations(), prefix)
+}
+
+func filterByPrefix(m map[string]string, prefix string) map[string]string {
+	filtered := make(map[string]string)
+	for k, v := range m {
+		if strings.HasPrefix(k, prefix) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
+// GetPodTemplateLabels - get pod template labels
+func (r *GenericResource) GetPodTemplateLabels() map[string]string {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return getOrInitialise(obj.Spec.Template.GetLabels())
+	case *apps_v1.StatefulSet:
+		return getOrInitialise(obj.Spec.Template.GetLabels())
+	case *apps_v1.DaemonSet:
+		return getOrInitialise(obj.Spec.Template.GetLabels())
+	case *batch_v1.CronJob:
+		return getOrInitialise(obj.Spec.JobTemplate.Spec.Template.GetLabels())
+	}
+	return nil
+}
+
+// SetPodTemplateLabels - set pod template labels. Changing a label will
+// trigger a rollout. Only use it for labels which are not matched by the
+// workload selector, changing those will either be rejected by the API server
+// or orphan existing pods.
+func (r *GenericResource) SetPodTemplateLabels(labels map[string]string) {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		obj.Spec.Template.SetLabels(labels)
+	case *apps_v1.StatefulSet:
+		obj.Spec.Template.SetLabels(labels)
+	case *apps_v1.DaemonSet:
+		obj.Spec.Template.SetLabels(labels)
+	case *batch_v1.CronJob:
+		obj.Spec.JobTemplate.Spec.Template.SetLabels(labels)
+	}
+}
+
+// GetTerminationGracePeriodSeconds - returns pod termination grace period,
+// nil when it's not set (Kubernetes defaults to 30 seconds)
+func (r *GenericResource) GetTerminationGracePeriodSeconds() *int64 {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return obj.Spec.Template.Spec.TerminationGracePeriodSeconds
+	case *apps_v1.StatefulSet:
+		return obj.Spec.Template.Spec.TerminationGracePeriodSeconds
+	case *apps_v1.DaemonSet:
+		return obj.Spec.Template.Spec.TerminationGracePeriodSeconds
+	case *batch_v1.CronJob:
+		return obj.Spec.JobTemplate.Spec.Template.Spec.TerminationGracePeriodSeconds
+	}
+	return nil
+}
+
+// GetContainerResources - returns a copy of the named container resource requirements
+func (r *GenericResource) GetContainerResources(name string) (core_v1.ResourceRequirements, bool) {
+	c, ok := r.getContainer(name)
+	if !ok {
+		return core_v1.ResourceRequirements{}, false
+	}
+	return *c.Resources.DeepCopy(), true
+}
+
+// ContainerImage - container name and its image
+type ContainerImage struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Init  bool   `json:"init"`
+}
+
+// ImageInventory - returns images grouped with their container names,
+// regular containers come first (in spec order), then init containers
+func (r *GenericResource) ImageInventory() []ContainerImage {
+	inventory := []ContainerImage{}
+	for _, c := range r.Containers() {
+		inventory = append(inventory, ContainerImage{Name: c.Name, Image: c.Image})
+	}
+	for _, c := range r.InitContainers() {
+		inventory = append(inventory, ContainerImage{Name: c.Name, Image: c.Image, Init: true})
+	}
+	return inventory
+}
+
+// IsPaused - returns true when deployment is paused, updates to paused
+// deployments won't be rolled out until they are resumed
+func (r *GenericResource) IsPaused() bool {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return obj.Spec.Paused
+	}
+	return false
+}
+
+// GetContainerEnvValue - returns literal value of the env variable of the named
+// container, variables referencing other sources (valueFrom) are not returned
+func (r *GenericResource) GetContainerEnvValue(containerName, key string) (string, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return "", false
+	}
+	for _, env := range c.Env {
+		if env.Name != key {
+			continue
+		}
+		if env.ValueFrom != nil {
+			return "", false
+		}
+		return env.Value, true
+	}
+	return "", false
+}
+
+// StampUpdateTime - sets update time annotation on the pod template so
+// the change gets rolled out. Time is recorded with nanosecond precision,
+// every stamp changes the template even within the same second. CronJob
+// stamp lands on the job's pod template (spec.jobTemplate.spec.template),
+// not on the job template metadata returned by GetSpecAnnotations.
+func (r *GenericResource) StampUpdateTime(t time.Time) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return
+	}
+	annotations := getOrInitialise(template.GetAnnotations())
+	annotations[types.KeelUpdateTimeAnnotation] = t.Format(time.RFC3339Nano)
+	template.SetAnnotations(annotations)
+}
+
+// LastUpdateTime - returns update time stamped on the pod template
+func (r *GenericResource) LastUpdateTime() (time.Time, bool) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return time.Time{}, false
+	}
+	value, ok := template.GetAnnotations()[types.KeelUpdateTimeAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// GetContainerWorkingDir - returns working directory override of the named
+// container, empty string when it's not set
+func (r *GenericResource) GetContainerWorkingDir(name string) (string, bool) {
+	c, ok := r.getContainer(name)
+	if !ok {
+		return "", false
+	}
+	return c.WorkingDir, true
+}
+
+// ValidationErrors - list of problems found while validating a resource
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d validation error(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Validate - checks whether resource is internally consistent: it has at least one
+// container, every container has a name and an image and the selector is set
+// (where applicable). Returns ValidationErrors listing all problems.
+func (r *GenericResource) Validate() error {
+	var errs ValidationErrors
+
+	if len(r.Containers()) == 0 {
+		errs = append(errs, fmt.Errorf("no containers found"))
+	}
+
+	for idx, c := range r.Containers() {
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf("container %d has no name", idx))
+		}
+		if c.Image == "" {
+			errs = append(errs, fmt.Errorf("container %d (%s) has no image", idx, c.Name))
+		}
+	}
+
+	for idx, c := range r.InitContainers() {
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf("init container %d has no name", idx))
+		}
+		if c.Image == "" {
+			errs = append(errs, fmt.Errorf("init container %d (%s) has no image", idx, c.Name))
+		}
+	}
+
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		if obj.Spec.Selector == nil {
+			errs = append(errs, fmt.Errorf("selector is not set"))
+		}
+	case *apps_v1.StatefulSet:
+		if obj.Spec.Selector == nil {
+			errs = append(errs, fmt.Errorf("selector is not set"))
+		}
+	case *apps_v1.DaemonSet:
+		if obj.Spec.Selector == nil {
+			errs = append(errs, fmt.Errorf("selector is not set"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// GetReadinessGates - returns a copy of the pod readiness gates
+func (r *GenericResource) GetReadinessGates() []core_v1.PodReadinessGate {
+	gates := []core_v1.PodReadinessGate{}
+	podSpec := r.getPodSpec()
+	if podSpec == nil {
+		return gates
+	}
+	for _, g := range podSpec.ReadinessGates {
+		gates = append(gates, *g.DeepCopy())
+	}
+	return gates
+}
+
+// DefaultReadyTimeout - ready timeout used when resource has no probes configured
+const DefaultReadyTimeout = 5 * time.Minute
+
+// probe defaults as documented in the Kubernetes API
+const (
+	defaultProbePeriodSeconds    = 10
+	defaultProbeFailureThreshold = 3
+)
+
+// EstimatedReadyTimeout - estimates how long it can take for the pods of this
+// resource to become ready, based on startup and readiness probes of the slowest
+// container plus MinReadySeconds. Returns DefaultReadyTimeout (plus MinReadySeconds)
+// when no probes are configured.
+func (r *GenericResource) EstimatedReadyTimeout() time.Duration {
+	var longest int32
+	found := false
+	for _, c := range r.Containers() {
+		var seconds int32
+		if c.StartupProbe != nil {
+			seconds += probeBudgetSeconds(c.StartupProbe)
+			found = true
+		}
+		if c.ReadinessProbe != nil {
+			seconds += probeBudgetSeconds(c.ReadinessProbe)
+			found = true
+		}
+		if seconds > longest {
+			longest = seconds
+		}
+	}
+
+	timeout := DefaultReadyTimeout
+	if found {
+		timeout = time.Duration(longest) * time.Second
+	}
+
+	var minReadySeconds int32
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		minReadySeconds = obj.Spec.MinReadySeconds
+	case *apps_v1.StatefulSet:
+		minReadySeconds = obj.Spec.MinReadySeconds
+	case *apps_v1.DaemonSet:
+		minReadySeconds = obj.Spec.MinReadySeconds
+	}
+
+	return timeout + time.Duration(minReadySeconds)*time.Second
+}
+
+// probeBudgetSeconds - the longest time a probe can take before it fails
+func probeBudgetSeconds(p *core_v1.Probe) int32 {
+	period := p.PeriodSeconds
+	if period == 0 {
+		period = defaultProbePeriodSeconds
+	}
+	threshold := p.FailureThreshold
+	if threshold == 0 {
+		threshold = defaultProbeFailureThreshold
+	}
+	return p.InitialDelaySeconds + period*threshold
+}
+
+// GetRevisionHistoryLimit - returns revision history limit, nil when it's not
+// set (Kubernetes defaults to 10) or not applicable (cronjobs)
+func (r *GenericResource) GetRevisionHistoryLimit() *int32 {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		return obj.Spec.RevisionHistoryLimit
+	case *apps_v1.StatefulSet:
+		return obj.Spec.RevisionHistoryLimit
+	case *apps_v1.DaemonSet:
+		return obj.Spec.RevisionHistoryLimit
+	}
+	return nil
+}
+
+// GetLifecycle - returns a copy of the named container lifecycle hooks,
+// nil when container has no hooks
+func (r *GenericResource) GetLifecycle(containerName string) (*core_v1.Lifecycle, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return nil, false
+	}
+	return c.Lifecycle.DeepCopy(), true
+}
+
+// MergeAnnotationsFrom - copies annotations set on the latest version of the resource
+// (i.e. by other controllers) which are missing on the receiver. Keel's own
+// annotations are never copied so the receiver stays authoritative for them.
+func (r *GenericResource) MergeAnnotationsFrom(latest *GenericResource) {
+	if latest == nil {
+		return
+	}
+	r.SetAnnotations(mergeMissing(r.GetAnnotations(), latest.GetAnnotations()))
+}
+
+// MergePodTemplateAnnotationsFrom - same as MergeAnnotationsFrom, but for
+// pod template annotations
+func (r *GenericResource) MergePodTemplateAnnotationsFrom(latest *GenericResource) {
+	if latest == nil {
+		return
+	}
+	template := r.getPodTemplate()
+	latestTemplate := latest.getPodTemplate()
+	if template == nil || latestTemplate == nil {
+		return
+	}
+	template.SetAnnotations(mergeMissing(getOrInitialise(template.GetAnnotations()), latestTemplate.GetAnnotations()))
+}
+
+func mergeMissing(dst, src map[string]string) map[string]string {
+	for k, v := range src {
+		if strings.HasPrefix(k, types.KeelAnnotationPrefix) {
+			continue
+		}
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+	return dst
+}
+
+// dockerHubRegistry - registry host used for images without one
+const dockerHubRegistry = "docker.io"
+
+// normalizedRegistry - returns registry host of the reference, Docker Hub
+// images are reported as docker.io
+func normalizedRegistry(ref *image.Reference) string {
+	if ref.Registry() == image.DefaultRegistryHostname {
+		return dockerHubRegistry
+	}
+	return ref.Registry()
+}
+
+// normalizedRepository - returns fully qualified repository of the
+// reference (i.e. docker.io/library/nginx)
+func normalizedRepository(ref *image.Reference) string {
+	return normalizedRegistry(ref) + "/" + ref.ShortName()
+}
+
+// normalizedImage - returns fully qualified image reference
+// (i.e. docker.io/library/nginx:latest)
+func normalizedImage(ref *image.Reference) string {
+	return normalizedRepository(ref) + strings.TrimPrefix(ref.Remote(), ref.Repository())
+}
+
+// isDigestReference - returns true when reference is pinned by digest
+func isDigestReference(ref *image.Reference) bool {
+	return strings.HasPrefix(strings.TrimPrefix(ref.Remote(), ref.Repository()), "@")
+}
+
+// ImagesByRegistry - returns repositories of regular and init containers
+// grouped by their registry host
+func (r *GenericResource) ImagesByRegistry() map[string][]string {
+	grouped := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, img := range append(r.GetImages(), r.GetInitImages()...) {
+		ref, err := image.Parse(img)
+		if err != nil {
+			continue
+		}
+		repository := normalizedRepository(ref)
+		if seen[repository] {
+			continue
+		}
+		seen[repository] = true
+		registry := normalizedRegistry(ref)
+		grouped[registry] = append(grouped[registry], repository)
+	}
+	for registry := range grouped {
+		sort.Strings(grouped[registry])
+	}
+	return grouped
+}
+
+// NeedsUpdate - returns true when any of the containers (regular or init) runs
+// a different image than desired, desired maps container names to images.
+// Containers missing from the map are ignored.
+func (r *GenericResource) NeedsUpdate(desired map[string]string) bool {
+	for _, c := range r.allContainers() {
+		if img, ok := desired[c.Name]; ok && img != c.Image {
+			return true
+		}
+	}
+	return false
+}
+
+// ScaleTargetRef - returns identity of the resource as it would be referenced by a
+// HorizontalPodAutoscaler scale target, ok is false for kinds that can't be scaled
+func (r *GenericResource) ScaleTargetRef() (apiVersion, kind, name string, ok bool) {
+	switch r.obj.(type) {
+	case *apps_v1.Deployment, *apps_v1.StatefulSet:
+		gvk := r.GVK()
+		return gvk.GroupVersion().String(), gvk.Kind, r.GetName(), true
+	}
+	return "", "", "", false
+}
+
+// PullSecretsForImage - returns names of the pull secrets that could authenticate
+// the image. Kubernetes tries every pod spec secret, so for now all of them are
+// returned regardless of the image registry. Service account secrets and the
+// keel.sh/imagePullSecret annotation are not included.
+func (r *GenericResource) PullSecretsForImage(img string) []string {
+	return r.GetImagePullSecrets()
+}
+
+// GetNormalizedImages - returns fully qualified images of regular and init
+// containers (i.e. nginx becomes docker.io/library/nginx:latest), images which
+// can't be parsed are returned unchanged
+func (r *GenericResource) GetNormalizedImages() []string {
+	var images []string
+	for _, img := range append(r.GetImages(), r.GetInitImages()...) {
+		ref, err := image.Parse(img)
+		if err != nil {
+			images = append(images, img)
+			continue
+		}
+		images = append(images, normalizedImage(ref))
+	}
+	return images
+}
+
+// SetUpdateSource - records which trigger (polling, webhook, approval) caused the update.
+// It's set on the resource annotations so it doesn't cause an extra rollout.
+func (r *GenericResource) SetUpdateSource(source string) {
+	annotations := r.GetAnnotations()
+	annotations[types.KeelUpdateSourceAnnotation] = source
+	r.SetAnnotations(annotations)
+}
+
+// GetUpdateSource - returns trigger that caused the last update
+func (r *GenericResource) GetUpdateSource() string {
+	return r.GetAnnotations()[types.KeelUpdateSourceAnnotation]
+}
+
+// GetContainerTag - returns tag of the named container image ignoring digest (i.e. 1.2
+// for nginx:1.2@sha256:...), latest when the image has no tag and empty string for
+// images pinned by digest only. Returns false when container isn't found or its image
+// can't be parsed.
+func (r *GenericResource) GetContainerTag(containerName string) (string, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return "", false
+	}
+	tag, err := imageTag(c.Image)
+	if err != nil {
+		return "", false
+	}
+	return tag, true
+}
+
+// imageTag - returns tag of the image with digest removed, image.Parse drops the tag
+// of references which also have a digest
+func imageTag(img string) (string, error) {
+	if _, err := image.Parse(img); err != nil {
+		return "", err
+	}
+	if isDigestOnly(img) {
+		return "", nil
+	}
+	ref, err := image.Parse(stripDigest(img))
+	if err != nil {
+		return "", err
+	}
+	return ref.Tag(), nil
+}
+
+// GetTopologySpreadConstraints - returns a copy of the pod topology spread constraints
+func (r *GenericResource) GetTopologySpreadConstraints() []core_v1.TopologySpreadConstraint {
+	constraints := []core_v1.TopologySpreadConstraint{}
+	podSpec := r.getPodSpec()
+	if podSpec == nil {
+		return constraints
+	}
+	for _, c := range podSpec.TopologySpreadConstraints {
+		constraints = append(constraints, *c.DeepCopy())
+	}
+	return constraints
+}
+
+// ResourceReport - flat view of the resource for metrics exporters
+type ResourceReport struct {
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	Identifier      string `json:"identifier"`
+	ImageCount      int    `json:"imageCount"`
+	ReadyReplicas   int32  `json:"readyReplicas"`
+	DesiredReplicas int32  `json:"desiredReplicas"`
+	// Managed is true when resource has a Keel policy set
+	Managed bool `json:"managed"`
+}
+
+// Report - returns a report of the resource, it doesn't reference the resource
+func (r *GenericResource) Report() ResourceReport {
+	plc := policy.GetPolicyFromLabelsOrAnnotations(r.GetLabels(), r.GetAnnotations())
+	return ResourceReport{
+		Kind:            r.Kind(),
+		Namespace:       r.GetNamespace(),
+		Name:            r.GetName(),
+		Identifier:      r.GetIdentifier(),
+		ImageCount:      len(r.Containers()) + len(r.InitContainers()),
+		ReadyReplicas:   r.GetStatus().ReadyReplicas,
+		DesiredReplicas: r.DesiredPods(),
+		Managed:         plc.Type() != policy.PolicyTypeNone,
+	}
+}
+
+// DesiredPods - returns desired number of pods: spec replicas for deployments and
+// statefulsets (1 when not set), desired number of scheduled pods for daemonsets
+// and 0 for cronjobs
+func (r *GenericResource) DesiredPods() int32 {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		if obj.Spec.Replicas == nil {
+			return 1
+		}
+		return *obj.Spec.Replicas
+	case *apps_v1.StatefulSet:
+		if obj.Spec.Replicas == nil {
+			return 1
+		}
+		return *obj.Spec.Replicas
+	case *apps_v1.DaemonSet:
+		return obj.Status.DesiredNumberScheduled
+	}
+	return 0
+}
+
+// InitContainerOrder - returns init container names in the order they are executed
+func (r *GenericResource) InitContainerOrder() []string {
+	names := []string{}
+	for _, c := range r.InitContainers() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// SetContainerImageIfChanged - updates container image only when it differs from
+// the current one, returns whether the image was changed
+func (r *GenericResource) SetContainerImageIfChanged(index int, image string) (changed bool, err error) {
+	if err := r.checkContainerIndex(index); err != nil {
+		return false, err
+	}
+	if r.Containers()[index].Image == image {
+		return false, nil
+	}
+	r.UpdateContainer(index, image)
+	return true, nil
+}
+
+// CloneToNamespace - returns a copy of the resource in another namespace,
+// resource version and UID are cleared so the copy can be created
+func (r *GenericResource) CloneToNamespace(ns string) *GenericResource {
+	gr := r.DeepCopy()
+
+	meta := gr.getObjectMeta()
+	if meta == nil {
+		return gr
+	}
+
+	meta.Namespace = ns
+	meta.ResourceVersion = ""
+	meta.UID = ""
+
+	gr.Identifier = gr.GetIdentifier()
+	gr.Namespace = gr.GetNamespace()
+	gr.Name = gr.GetName()
+
+	return gr
+}
+
+// Repositories - returns sorted fully qualified repositories (without tags or
+// digests) used by regular and init containers
+func (r *GenericResource) Repositories() []string {
+	seen := make(map[string]bool)
+	repositories := []string{}
+	for _, img := range append(r.GetImages(), r.GetInitImages()...) {
+		ref, err := image.Parse(img)
+		if err != nil {
+			continue
+		}
+		repository := normalizedRepository(ref)
+		if seen[repository] {
+			continue
+		}
+		seen[repository] = true
+		repositories = append(repositories, repository)
+	}
+	sort.Strings(repositories)
+	return repositories
+}
+
+// trackedDigestAnnotation - annotation key holding the last resolved digest of a container
+func trackedDigestAnnotation(containerName string) string {
+	return types.KeelDigestAnnotation + "." + containerName
+}
+
+// SetTrackedDigest - records the digest the named container image tag resolved to
+func (r *GenericResource) SetTrackedDigest(containerName, digest string) {
+	annotations := r.GetAnnotations()
+	annotations[trackedDigestAnnotation(containerName)] = digest
+	r.SetAnnotations(annotations)
+}
+
+// GetTrackedDigest - returns the recorded digest of the named container
+func (r *GenericResource) GetTrackedDigest(containerName string) (string, bool) {
+	digest, ok := r.GetAnnotations()[trackedDigestAnnotation(containerName)]
+	return digest, ok
+}
+
+// ContainersWithStaleDigest - returns names of the containers (regular and init)
+// running the tag whose recorded digest differs from the current one. Containers
+// without a recorded digest are skipped.
+func (r *GenericResource) ContainersWithStaleDigest(tag, currentDigest string) []string {
+	stale := []string{}
+	for _, c := range r.allContainers() {
+		ref, err := image.Parse(c.Image)
+		if err != nil || isDigestReference(ref) || ref.Tag() != tag {
+			continue
+		}
+		recorded, ok := r.GetTrackedDigest(c.Name)
+		if !ok {
+			continue
+		}
+		if recorded != currentDigest {
+			stale = append(stale, c.Name)
+		}
+	}
+	return stale
+}
+
+// EffectivePullPolicy - returns pull policy of the named container, when it's not
+// set Kubernetes defaults to Always for the latest tag and IfNotPresent otherwise
+func (r *GenericResource) EffectivePullPolicy(containerName string) (core_v1.PullPolicy, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return "", false
+	}
+	if c.ImagePullPolicy != "" {
+		return c.ImagePullPolicy, true
+	}
+	if tag, _ := r.GetContainerTag(containerName); tag == image.DefaultTag {
+		return core_v1.PullAlways, true
+	}
+	return core_v1.PullIfNotPresent, true
+}
+
+// IsContainerDigestPinned - returns true when the named container image is pinned
+// by digest (i.e. nginx@sha256:...), second value is false when container isn't found
+func (r *GenericResource) IsContainerDigestPinned(name string) (bool, bool) {
+	c, ok := r.getContainer(name)
+	if !ok {
+		return false, false
+	}
+	ref, err := image.Parse(c.Image)
+	if err != nil {
+		return false, true
+	}
+	return isDigestReference(ref), true
+}
+
+// ImageUsageCounts - returns how many containers (regular and init) use each image,
+// images are normalized so nginx and docker.io/library/nginx:latest are counted together
+func (r *GenericResource) ImageUsageCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, img := range r.GetNormalizedImages() {
+		counts[img]++
+	}
+	return counts
+}
+
+// PolicyAnnotation - returns policy set in the keel.sh/policy annotation
+func (r *GenericResource) PolicyAnnotation() string {
+	return r.GetAnnotations()[types.KeelPolicyAnnotation]
+}
+
+// SetPolicy - sets keel.sh/policy annotation
+func (r *GenericResource) SetPolicy(policy string) {
+	annotations := r.GetAnnotations()
+	annotations[types.KeelPolicyAnnotation] = policy
+	r.SetAnnotations(annotations)
+}
+
+// ClearPolicy - removes keel.sh/policy annotation, keel.sh/policy label (possibly
+// owned by other tooling) is left untouched
+func (r *GenericResource) ClearPolicy() {
+	annotations := r.GetAnnotations()
+	delete(annotations, types.KeelPolicyAnnotation)
+	r.SetAnnotations(annotations)
+}
+
+// AggregateResources - returns effective pod requests and limits: sum of regular
+// containers or the largest init container, whichever is higher (init containers
+// run one at a time). Values are per pod, multiply by DesiredPods() for totals.
+func (r *GenericResource) AggregateResources() (requests, limits core_v1.ResourceList) {
+	requests = core_v1.ResourceList{}
+	limits = core_v1.ResourceList{}
+
+	for _, c := range r.Containers() {
+		addResources(requests, c.Resources.Requests)
+		addResources(limits, c.Resources.Limits)
+	}
+
+	for _, c := range r.InitContainers() {
+		maxResources(requests, c.Resources.Requests)
+		maxResources(limits, c.Resources.Limits)
+	}
+
+	return requests, limits
+}
+
+func addResources(dst, src core_v1.ResourceList) {
+	for name, quantity := range src {
+		total := dst[name]
+		total.Add(quantity)
+		dst[name] = total
+	}
+}
+
+func maxResources(dst, src core_v1.ResourceList) {
+	for name, quantity := range src {
+		if current, ok := dst[name]; !ok || quantity.Cmp(current) > 0 {
+			dst[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// HasFinalizer - returns true when resource has the finalizer
+func (r *GenericResource) HasFinalizer(name string) bool {
+	meta := r.getObjectMeta()
+	if meta == nil {
+		return false
+	}
+	for _, f := range meta.Finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFinalizer - adds finalizer unless resource already has it
+func (r *GenericResource) AddFinalizer(name string) {
+	meta := r.getObjectMeta()
+	if meta == nil || r.HasFinalizer(name) {
+		return
+	}
+	meta.Finalizers = append(meta.Finalizers, name)
+}
+
+// RemoveFinalizer - removes finalizer, it's a no-op if resource doesn't have it
+func (r *GenericResource) RemoveFinalizer(name string) {
+	meta := r.getObjectMeta()
+	if meta == nil {
+		return
+	}
+	var finalizers []string
+	for _, f := range meta.Finalizers {
+		if f != name {
+			finalizers = append(finalizers, f)
+		}
+	}
+	meta.Finalizers = finalizers
+}
+
+// TargetArchitecture - returns target architecture set in the keel.sh/arch annotation
+func (r *GenericResource) TargetArchitecture() (string, bool) {
+	arch, ok := r.GetAnnotations()[types.KeelArchitectureAnnotation]
+	if !ok || arch == "" {
+		return "", false
+	}
+	return arch, true
+}
+
+// Touch - sets restartedAt annotation on the pod template to force a rollout without
+// changing images, same as kubectl rollout restart
+func (r *GenericResource) Touch(t time.Time) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return
+	}
+	annotations := getOrInitialise(template.GetAnnotations())
+	annotations[types.KubernetesRestartedAtAnnotation] = t.Format(time.RFC3339)
+	template.SetAnnotations(annotations)
+}
+
+// GetPullHint - returns expected image pull duration of the named container,
+// false when the annotation is not set or can't be parsed
+func (r *GenericResource) GetPullHint(containerName string) (time.Duration, bool) {
+	value, ok := r.GetAnnotations()[types.KeelPullHintAnnotationPrefix+containerName]
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
+// ContainersSorted - returns a copy of regular containers sorted by name,
+// use Containers() for the spec order
+func (r *GenericResource) ContainersSorted() []core_v1.Container {
+	containers := []core_v1.Container{}
+	for _, c := range r.Containers() {
+		containers = append(containers, *c.DeepCopy())
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		return containers[i].Name < containers[j].Name
+	})
+	return containers
+}
+
+// PrimaryImage - returns image of the first regular container, false when resource has
+// no containers. Only meaningful for single container workloads.
+func (r *GenericResource) PrimaryImage() (string, bool) {
+	containers := r.Containers()
+	if len(containers) == 0 {
+		return "", false
+	}
+	return containers[0].Image, true
+}
+
+// splitImage - splits image into repository and tag/digest suffix
+// (i.e. gcr.io/app:1.0 into gcr.io/app and :1.0)
+func splitImage(img string) (repository, suffix string) {
+	if i := strings.Index(img, "@"); i != -1 {
+		repository, suffix = img[:i], img[i:]
+		if j := strings.LastIndex(repository, ":"); j > strings.LastIndex(repository, "/") {
+			repository, suffix = repository[:j], repository[j:]+suffix
+		}
+		return repository, suffix
+	}
+	if i := strings.LastIndex(img, ":"); i > strings.LastIndex(img, "/") {
+		return img[:i], img[i:]
+	}
+	return img, ""
+}
+
+// RebaseImages - replaces oldRepoPrefix with newRepoPrefix in repositories of regular
+// and init containers preserving their tags and digests, returns number of updated
+// containers. Prefixes match whole path segments only: "gcr.io/org" matches gcr.io/org
+// and gcr.io/org/app but not gcr.io/organization/app. Repositories are matched as
+// written in the spec first, then in their fully qualified form (i.e. docker.io/library/nginx).
+func (r *GenericResource) RebaseImages(oldRepoPrefix, newRepoPrefix string) int {
+	oldRepoPrefix = strings.TrimSuffix(oldRepoPrefix, "/")
+	newRepoPrefix = strings.TrimSuffix(newRepoPrefix, "/")
+
+	rebaseRepository := func(repository string) (string, bool) {
+		if repository == oldRepoPrefix || strings.HasPrefix(repository, oldRepoPrefix+"/") {
+			return newRepoPrefix + strings.TrimPrefix(repository, oldRepoPrefix), true
+		}
+		return "", false
+	}
+
+	rebase := func(img string) (string, bool) {
+		repository, suffix := splitImage(img)
+		if rebased, ok := rebaseRepository(repository); ok {
+			return rebased + suffix, true
+		}
+		ref, err := image.Parse(img)
+		if err != nil {
+			return "", false
+		}
+		if rebased, ok := rebaseRepository(normalizedRepository(ref)); ok {
+			return rebased + suffix, true
+		}
+		return "", false
+	}
+
+	changed := 0
+	for idx, c := range r.Containers() {
+		if img, ok := rebase(c.Image); ok {
+			r.UpdateContainer(idx, img)
+			changed++
+		}
+	}
+	for idx, c := range r.InitContainers() {
+		if img, ok := rebase(c.Image); ok {
+			r.UpdateInitContainer(idx, img)
+			changed++
+		}
+	}
+	return changed
+}
+
+// PodTemplateLabelValue - returns value of the pod template label
+func (r *GenericResource) PodTemplateLabelValue(key string) (string, bool) {
+	value, ok := r.GetPodTemplateLabels()[key]
+	return value, ok
+}
+
+// ValidateImageUpdate - guards against updates pointing the container at a different
+// repository (unless allowed with the keel.sh/allowRepositoryChange annotation) or
+// downgrading it across a major version
+func (r *GenericResource) ValidateImageUpdate(index int, newImage string) error {
+	if err := r.checkContainerIndex(index); err != nil {
+		return err
+	}
+
+	current, err := image.Parse(r.Containers()[index].Image)
+	if err != nil {
+		return fmt.Errorf("failed to parse current image: %s", err)
+	}
+
+	proposed, err := image.Parse(newImage)
+	if err != nil {
+		return fmt.Errorf("failed to parse new image: %s", err)
+	}
+
+	if current.Repository() != proposed.Repository() && r.GetAnnotations()[types.KeelAllowRepositoryChangeAnnotation] != "true" {
+		return fmt.Errorf("repository change from %s to %s is not allowed", current.Repository(), proposed.Repository())
+	}
+
+	currentVersion, err := semver.NewVersion(current.Tag())
+	if err != nil {
+		return nil
+	}
+	proposedVersion, err := semver.NewVersion(proposed.Tag())
+	if err != nil {
+		return nil
+	}
+	if proposedVersion.Major() < currentVersion.Major() {
+		return fmt.Errorf("major version downgrade from %s to %s is not allowed", current.Tag(), proposed.Tag())
+	}
+
+	return nil
+}
+
+// PodTemplateHashLabel - returns pod-template-hash label from the pod template or the
+// selector. Only deployments are checked, the label is set on their replicasets.
+func (r *GenericResource) PodTemplateHashLabel() (string, bool) {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		if hash, ok := obj.Spec.Template.Labels[apps_v1.DefaultDeploymentUniqueLabelKey]; ok {
+			return hash, true
+		}
+		if obj.Spec.Selector != nil {
+			if hash, ok := obj.Spec.Selector.MatchLabels[apps_v1.DefaultDeploymentUniqueLabelKey]; ok {
+				return hash, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ContainerRef - reference to a regular or init container of the resource
+type ContainerRef struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	// Index of the container in Containers() or InitContainers()
+	Index int  `json:"index"`
+	Init  bool `json:"init"`
+}
+
+// containerRefs - returns references to regular containers followed by init containers
+func (r *GenericResource) containerRefs() []ContainerRef {
+	var refs []ContainerRef
+	for idx, c := range r.Containers() {
+		refs = append(refs, ContainerRef{Name: c.Name, Image: c.Image, Index: idx})
+	}
+	for idx, c := range r.InitContainers() {
+		refs = append(refs, ContainerRef{Name: c.Name, Image: c.Image, Index: idx, Init: true})
+	}
+	return refs
+}
+
+// EligibleContainers - returns regular and init containers for which the predicate
+// (i.e. policy check) returns true
+func (r *GenericResource) EligibleContainers(predicate func(containerName, image string) bool) []ContainerRef {
+	eligible := []ContainerRef{}
+	for _, ref := range r.containerRefs() {
+		if predicate(ref.Name, ref.Image) {
+			eligible = append(eligible, ref)
+		}
+	}
+	return eligible
+}
+
+// RolloutMessage - returns human readable rollout state. For deployments it's based on
+// the Progressing condition (i.e. "rollout failed: ProgressDeadlineExceeded"), other
+// kinds derive it from replica numbers. Cronjobs don't have rollouts.
+func (r *GenericResource) RolloutMessage() (string, bool) {
+	switch obj := r.obj.(type) {
+	case *apps_v1.Deployment:
+		for _, c := range obj.Status.Conditions {
+			if c.Type != apps_v1.DeploymentProgressing {
+				continue
+			}
+			if c.Status == core_v1.ConditionFalse {
+				return fmt.Sprintf("rollout failed: %s: %s", c.Reason, c.Message), true
+			}
+			return fmt.Sprintf("%s: %s", c.Reason, c.Message), true
+		}
+	case *apps_v1.StatefulSet, *apps_v1.DaemonSet:
+	default:
+		return "", false
+	}
+
+	status := r.GetStatus()
+	return fmt.Sprintf("%d of %d replicas updated, %d ready", status.UpdatedReplicas, status.Replicas, status.ReadyReplicas), true
+}
+
+// OutdatedContainer - container with a newer tag available
+type OutdatedContainer struct {
+	Name      string `json:"name"`
+	Current   string `json:"current"`
+	Available string `json:"available"`
+}
+
+// OutdatedContainers - returns regular and init containers whose tag is older than the
+// latest tag supplied by the callback for their fully qualified repository
+// (i.e. docker.io/library/nginx). Only semver tags can be compared, other tags and
+// digest references are skipped.
+func (r *GenericResource) OutdatedContainers(latest func(repo string) (string, bool)) []OutdatedContainer {
+	outdated := []OutdatedContainer{}
+	for _, c := range r.allContainers() {
+		ref, err := image.Parse(c.Image)
+		if err != nil || isDigestReference(ref) {
+			continue
+		}
+		available, ok := latest(normalizedRepository(ref))
+		if !ok {
+			continue
+		}
+		currentVersion, err := semver.NewVersion(ref.Tag())
+		if err != nil {
+			continue
+		}
+		availableVersion, err := semver.NewVersion(available)
+		if err != nil {
+			continue
+		}
+		if currentVersion.LessThan(availableVersion) {
+			outdated = append(outdated, OutdatedContainer{
+				Name:      c.Name,
+				Current:   ref.Tag(),
+				Available: available,
+			})
+		}
+	}
+	return outdated
+}
+
+// ResolveUpdateTarget - picks the container to update for the repository when both
+// regular and init containers use it: the first matching init container when
+// preferInit is set, the first matching regular container otherwise. Falls back
+// to the other kind of container when preferred one doesn't use the repository.
+func (r *GenericResource) ResolveUpdateTarget(repo string, preferInit bool) (ContainerRef, bool) {
+	repoRef, err := image.Parse(repo)
+	if err != nil {
+		return ContainerRef{}, false
+	}
+	repository := normalizedRepository(repoRef)
+
+	var regular, initContainers []ContainerRef
+	for _, ref := range r.containerRefs() {
+		containerRef, err := image.Parse(ref.Image)
+		if err != nil || normalizedRepository(containerRef) != repository {
+			continue
+		}
+		if ref.Init {
+			initContainers = append(initContainers, ref)
+		} else {
+			regular = append(regular, ref)
+		}
+	}
+
+	preferred, fallback := regular, initContainers
+	if preferInit {
+		preferred, fallback = initContainers, regular
+	}
+	if len(preferred) > 0 {
+		return preferred[0], true
+	}
+	if len(fallback) > 0 {
+		return fallback[0], true
+	}
+	return ContainerRef{}, false
+}
+
+// EnsurePullAlwaysForTags - sets Always pull policy on regular and init containers
+// running one of the tags (i.e. mutable tags such as latest) so nodes re-pull the
+// image, returns number of updated containers
+func (r *GenericResource) EnsurePullAlwaysForTags(tags []string) int {
+	podSpec := r.getPodSpec()
+	if podSpec == nil {
+		return 0
+	}
+
+	floating := make(map[string]bool)
+	for _, tag := range tags {
+		floating[tag] = true
+	}
+
+	ensure := func(containers []core_v1.Container) int {
+		changed := 0
+		for i := range containers {
+			if containers[i].ImagePullPolicy == core_v1.PullAlways {
+				continue
+			}
+			tag, err := imageTag(containers[i].Image)
+			if err != nil || !floating[tag] {
+				continue
+			}
+			containers[i].ImagePullPolicy = core_v1.PullAlways
+			changed++
+		}
+		return changed
+	}
+
+	return ensure(podSpec.Containers) + ensure(podSpec.InitContainers)
+}
+
+// NodeSelectorArchitecture - returns architecture the pods are scheduled to by the
+// kubernetes.io/arch (or legacy beta.kubernetes.io/arch) node selector
+func (r *GenericResource) NodeSelectorArchitecture() (string, bool) {
+	podSpec := r.getPodSpec()
+	if podSpec == nil {
+		return "", false
+	}
+	if arch, ok := podSpec.NodeSelector[core_v1.LabelArchStable]; ok {
+		return arch, true
+	}
+	if arch, ok := podSpec.NodeSelector["beta.kubernetes.io/arch"]; ok {
+		return arch, true
+	}
+	return "", false
+}
+
+// ContainerTagsAreSemver - returns whether current tag of each regular and init
+// container is a valid semantic version, keyed by container name
+func (r *GenericResource) ContainerTagsAreSemver() map[string]bool {
+	result := make(map[string]bool)
+	for _, c := range r.allContainers() {
+		tag, _ := r.GetContainerTag(c.Name)
+		_, err := semver.NewVersion(tag)
+		result[c.Name] = tag != "" && err == nil
+	}
+	return result
+}
+
+// IsSuspended - returns true when CronJob is suspended, other kinds are never suspended
+func (r *GenericResource) IsSuspended() bool {
+	switch obj := r.obj.(type) {
+	case *batch_v1.CronJob:
+		return obj.Spec.Suspend != nil && *obj.Spec.Suspend
+	}
+	return false
+}
+
+// ContainerImageTagOnly - returns image of the named container with digest
+// suffix (@sha256:...) stripped, tag is preserved. Returns false when container
+// isn't found or its image is pinned by digest only (i.e. nginx@sha256:...),
+// stripping the digest would silently switch it to the latest tag.
+func (r *GenericResource) ContainerImageTagOnly(containerName string) (string, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok || isDigestOnly(c.Image) {
+		return "", false
+	}
+	return stripDigest(c.Image), true
+}
+
+type pullFailure struct {
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// SetPullFailure - records image pull failure of the named container observed at t
+// in the workload annotations
+func (r *GenericResource) SetPullFailure(containerName, reason string, t time.Time) {
+	encoded, err := json.Marshal(pullFailure{Reason: reason, Time: t.UTC()})
+	if err != nil {
+		return
+	}
+	annotations := r.GetAnnotations()
+	annotations[types.KeelPullFailureAnnotationPrefix+containerName] = string(encoded)
+	r.SetAnnotations(annotations)
+}
+
+// GetPullFailure - returns recorded image pull failure of the named container,
+// false when none was recorded or it can't be decoded
+func (r *GenericResource) GetPullFailure(containerName string) (reason string, when time.Time, ok bool) {
+	value, found := r.GetAnnotations()[types.KeelPullFailureAnnotationPrefix+containerName]
+	if !found {
+		return "", time.Time{}, false
+	}
+	var failure pullFailure
+	if err := json.Unmarshal([]byte(value), &failure); err != nil {
+		return "", time.Time{}, false
+	}
+	return failure.Reason, failure.Time, true
+}
+
+// ImageReference - parsed image of a regular or init container
+type ImageReference struct {
+	Container string
+	Init      bool
+	Reference *image.Reference
+}
+
+// ImageParseError - image of the container that couldn't be parsed
+type ImageParseError struct {
+	Container string
+	Image     string
+	Err       error
+}
+
+func (e *ImageParseError) Error() string {
+	return fmt.Sprintf("failed to parse image %s in container %s: %s", e.Image, e.Container, e.Err)
+}
+
+func (e *ImageParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseImages - parses images of regular and init containers, images that can't
+// be parsed are returned as *ImageParseError instead of being skipped
+func (r *GenericResource) ParseImages() ([]ImageReference, []error) {
+	refs := []ImageReference{}
+	var errs []error
+	for _, c := range r.containerRefs() {
+		ref, err := image.Parse(c.Image)
+		if err != nil {
+			errs = append(errs, &ImageParseError{Container: c.Name, Image: c.Image, Err: err})
+			continue
+		}
+		refs = append(refs, ImageReference{Container: c.Name, Init: c.Init, Reference: ref})
+	}
+	return refs, errs
+}
+
+// PreviewUpdate - renders proposed image updates (container name -> new image) as
+// "web: nginx:1.2 -> nginx:1.3" lines in container order. Returns an error listing
+// container names that don't exist in the resource or when two names refer to the
+// same container.
+func (r *GenericResource) PreviewUpdate(updates map[string]string) (string, error) {
+	if err := r.checkContainerNames(updates); err != nil {
+		return "", err
+	}
+
+	var lines []string
+	found := make(map[string]bool)
+	for _, c := range r.containerRefs() {
+		key := updateKey(updates, c)
+		newImage, ok := updates[key]
+		if !ok || found[key] {
+			continue
+		}
+		found[key] = true
+		lines = append(lines, fmt.Sprintf("%s: %s -> %s", c.Name, c.Image, newImage))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// ForceUpdateImage - sets image of the container at index and always stamps update
+// time on the pod template, so the workload is rolled even when the image is unchanged
+func (r *GenericResource) ForceUpdateImage(index int, image string, t time.Time) error {
+	if err := r.checkContainerIndex(index); err != nil {
+		return err
+	}
+	r.UpdateContainer(index, image)
+	r.StampUpdateTime(t)
+	return nil
+}
+
+// CompareContainerTag - compares current tag of the named container to the candidate
+// tag, returns -1, 0 or 1 when current tag is lower, equal or higher. Tags are compared
+// as semantic versions when both parse, otherwise as strings and the result is
+// returned together with ErrTagNotSemver.
+func (r *GenericResource) CompareContainerTag(containerName, candidateTag string) (int, error) {
+	current, ok := r.GetContainerTag(containerName)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrContainerNotFound, containerName)
+	}
+
+	currentVersion, currentErr := semver.NewVersion(current)
+	candidateVersion, candidateErr := semver.NewVersion(candidateTag)
+	if current == "" || currentErr != nil || candidateErr != nil {
+		return strings.Compare(current, candidateTag), fmt.Errorf("%w: comparing %q and %q as strings", ErrTagNotSemver, current, candidateTag)
+	}
+	return currentVersion.Compare(candidateVersion), nil
+}
+
+// ContainersMatchingTagGlob - returns regular and init containers which current tag
+// matches the glob pattern (same matching as glob policies), containers pinned by digest
+// only never match. Returns an error when the pattern is empty.
+func (r *GenericResource) ContainersMatchingTagGlob(pattern string) ([]ContainerRef, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid glob pattern: pattern is empty")
+	}
+	matching := []ContainerRef{}
+	for _, c := range r.containerRefs() {
+		tag, _ := r.GetContainerTag(c.Name)
+		if tag == "" {
+			continue
+		}
+		if glob.Glob(pattern, tag) {
+			matching = append(matching, c)
+		}
+	}
+	return matching, nil
+}
+
+// SetResolvedDigests - records digests (container name -> digest) in a single
+// annotation, empty map removes the annotation
+func (r *GenericResource) SetResolvedDigests(digests map[string]string) {
+	annotations := r.GetAnnotations()
+	if len(digests) == 0 {
+		if _, ok := annotations[types.KeelResolvedDigestsAnnotation]; ok {
+			delete(annotations, types.KeelResolvedDigestsAnnotation)
+			r.SetAnnotations(annotations)
+		}
+		return
+	}
+
+	encoded, err := json.Marshal(digests)
+	if err != nil {
+		return
+	}
+	annotations[types.KeelResolvedDigestsAnnotation] = string(encoded)
+	r.SetAnnotations(annotations)
+}
+
+// GetResolvedDigests - returns recorded digests keyed by container name, empty
+// map when none were recorded or the annotation can't be decoded
+func (r *GenericResource) GetResolvedDigests() map[string]string {
+	digests := make(map[string]string)
+	value, ok := r.GetAnnotations()[types.KeelResolvedDigestsAnnotation]
+	if !ok {
+		return digests
+	}
+	if err := json.Unmarshal([]byte(value), &digests); err != nil {
+		return make(map[string]string)
+	}
+	return digests
+}
+
+// EnsureLabel - sets the label when it's missing or has a different value,
+// returns whether the resource was changed
+func (r *GenericResource) EnsureLabel(key, value string) bool {
+	labels := r.GetLabels()
+	if current, ok := labels[key]; ok && current == value {
+		return false
+	}
+	labels[key] = value
+	r.SetLabels(labels)
+	return true
+}
+
+// RemoveLabel - removes the label, returns whether the resource was changed
+func (r *GenericResource) RemoveLabel(key string) bool {
+	labels := r.GetLabels()
+	if _, ok := labels[key]; !ok {
+		return false
+	}
+	delete(labels, key)
+	r.SetLabels(labels)
+	return true
+}
+
+// eligibility reason codes returned by ExplainEligibility
+const (
+	EligibilityEligible       = "eligible"
+	EligibilityDigestPinned   = "digest-pinned"
+	EligibilityNoNewerTag     = "no-newer-tag"
+	EligibilityPolicyExcluded = "policy-excluded"
+	EligibilityInvalidIndex   = "invalid-index"
+)
+
+// ExplainEligibility - explains whether container at index would be updated. Policy
+// receives current image and returns the new image, false when the policy excludes
+// the container. Returns the new image (empty unless eligible) and a reason code.
+func (r *GenericResource) ExplainEligibility(index int, policy func(current string) (string, bool)) (update string, reason string) {
+	if err := r.checkContainerIndex(index); err != nil {
+		return "", EligibilityInvalidIndex
+	}
+
+	current := r.Containers()[index].Image
+	if ref, err := image.Parse(current); err == nil && isDigestReference(ref) {
+		return "", EligibilityDigestPinned
+	}
+
+	update, ok := policy(current)
+	if !ok {
+		return "", EligibilityPolicyExcluded
+	}
+	if update == "" || update == current {
+		return "", EligibilityNoNewerTag
+	}
+	return update, EligibilityEligible
+}
+
+// RebaseOnto - replaces the receiver's object with a copy of the live resource with only
+// the images the receiver changed since it was read (created with NewGenericResource)
+// applied by container name. Metadata (resource version, labels, annotations), the rest
+// of the spec and images changed by someone else come from live. Returns an error without
+// changing the receiver when kinds, namespaces or names differ or any of the changed
+// containers no longer exists in live.
+func (r *GenericResource) RebaseOnto(live *GenericResource) error {
+	if live == nil {
+		return fmt.Errorf("live resource is nil")
+	}
+	if r.Kind() != live.Kind() {
+		return fmt.Errorf("kind mismatch: %s != %s", r.Kind(), live.Kind())
+	}
+	if r.GetNamespace() != live.GetNamespace() || r.GetName() != live.GetName() {
+		return fmt.Errorf("resource mismatch: %s/%s != %s/%s", r.GetNamespace(), r.GetName(), live.GetNamespace(), live.GetName())
+	}
+
+	unchanged := make(map[ContainerImage]bool)
+	for _, img := range r.read {
+		unchanged[img] = true
+	}
+	changes := []ContainerImage{}
+	for _, img := range r.ImageInventory() {
+		if !unchanged[img] {
+			changes = append(changes, img)
+		}
+	}
+
+	rebased := live.DeepCopy()
+	if err := rebased.RestoreImages(ImageSnapshot{Images: changes}); err != nil {
+		return fmt.Errorf("failed to rebase %s onto live resource: %s", r.Identifier, err)
+	}
+
+	// only the object is replaced, identity fields are read without the lock through
+	// SafeResource
+	r.obj = rebased.obj
+	r.read = live.ImageInventory()
+	return nil
+}
+
+// ContainerPolicies - returns effective policy of each regular and init container keyed
+// by container name. Precedence: keel.sh/policy.<container> annotation, then workload
+// policy annotation, then workload policy label, workload policy is looked up the same
+// way as by the policy package (legacy keel.observer/policy key included). Containers
+// without any policy map to an empty string.
+func (r *GenericResource) ContainerPolicies() map[string]string {
+	annotations := r.GetAnnotations()
+
+	workloadPolicy, ok := policy.GetPolicyFromLabels(annotations)
+	if !ok {
+		workloadPolicy, _ = policy.GetPolicyFromLabels(r.GetLabels())
+	}
+
+	policies := make(map[string]string)
+	for _, c := range r.allContainers() {
+		if containerPolicy, ok := annotations[types.KeelContainerPolicyAnnotationPrefix+c.Name]; ok {
+			policies[c.Name] = containerPolicy
+			continue
+		}
+		policies[c.Name] = workloadPolicy
+	}
+	return policies
+}
+
+// UsesRegistry - returns true when any regular or init container runs an image from
+// the registry host, Docker Hub can be given as docker.io or index.docker.io
+func (r *GenericResource) UsesRegistry(registry string) bool {
+	registry = strings.ToLower(strings.TrimSuffix(registry, "/"))
+	if registry == image.DefaultRegistryHostname {
+		registry = dockerHubRegistry
+	}
+	for _, c := range r.allContainers() {
+		ref, err := image.Parse(c.Image)
+		if err != nil {
+			continue
+		}
+		if normalizedRegistry(ref) == registry {
+			return true
+		}
+	}
+	return false
+}
+
+// ForceUpdateImages - sets images of the named regular and init containers (container
+// name -> image) and stamps update time on the pod template once, so the workload is
+// rolled even when no image changed. Returns names of the containers which image
+// changed, in container order. The resource is left unchanged when any of the named
+// containers doesn't exist.
+func (r *GenericResource) ForceUpdateImages(updates map[string]string, t time.Time) (changed []string, err error) {
+	if err := r.checkContainerNames(updates); err != nil {
+		return nil, err
+	}
+
+	updated := r.DeepCopy()
+	changed = updated.applyImages(updates)
+	updated.StampUpdateTime(t)
+
+	r.obj = updated.obj
+	return changed, nil
+}
+
+// checkContainerNames - returns an error listing names of the updated containers
+// which don't exist in the resource, or when two names refer to the same container
+// (i.e. "setup" and "init:setup" when there's no regular container named setup)
+func (r *GenericResource) checkContainerNames(updates map[string]string) error {
+	names := make([]string, 0, len(updates))
+	for name := range updates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var missing []string
+	referred := make(map[*core_v1.Container]string)
+	for _, name := range names {
+		c, ok := r.getContainer(name)
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if other, ok := referred[c]; ok {
+			return fmt.Errorf("container names %s and %s refer to the same container", other, name)
+		}
+		referred[c] = name
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s, available containers: %s", ErrContainerNotFound, strings.Join(missing, ", "), strings.Join(r.ContainerNames(), ", "))
+	}
+	return nil
+}
+
+// applyImages - sets images of the named containers (regular containers take precedence
+// over init containers of the same name unless "init:" prefix is used), returns names
+// of the changed containers
+func (r *GenericResource) applyImages(updates map[string]string) []string {
+	changed := []string{}
+	applied := make(map[string]bool)
+	for _, c := range r.containerRefs() {
+		key := updateKey(updates, c)
+		newImage, ok := updates[key]
+		if !ok || applied[key] {
+			continue
+		}
+		applied[key] = true
+		if c.Image == newImage {
+			continue
+		}
+		if c.Init {
+			r.UpdateInitContainer(c.Index, newImage)
+		} else {
+			r.UpdateContainer(c.Index, newImage)
+		}
+		changed = append(changed, c.Name)
+	}
+	return changed
+}
+
+// ContainerTagEquals - returns whether the named container runs the same repository
+// and tag as the image, digests are ignored (i.e. nginx:1.2@sha256:... equals
+// docker.io/library/nginx:1.2). Images pinned by digest only (i.e. nginx@sha256:...)
+// have no tag to compare and never equal. Second value is false when the container
+// isn't found.
+func (r *GenericResource) ContainerTagEquals(containerName, img string) (equal bool, found bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return false, false
+	}
+	if isDigestOnly(c.Image) || isDigestOnly(img) {
+		return false, true
+	}
+	current, err := image.Parse(stripDigest(c.Image))
+	if err != nil {
+		return false, true
+	}
+	other, err := image.Parse(stripDigest(img))
+	if err != nil {
+		return false, true
+	}
+	return normalizedImage(current) == normalizedImage(other), true
+}
+
+// isDigestOnly - returns true when the image is pinned by digest without a tag
+func isDigestOnly(img string) bool {
+	if !strings.Contains(img, "@") {
+		return false
+	}
+	_, suffix := splitImage(stripDigest(img))
+	return suffix == ""
+}
+
+// stripDigest - removes @sha256:... suffix from the image
+func stripDigest(img string) string {
+	if i := strings.Index(img, "@"); i != -1 {
+		return img[:i]
+	}
+	return img
+}
+
+// ExternalURL - returns application URL set in the keel.sh/url annotation
+func (r *GenericResource) ExternalURL() (string, bool) {
+	url, ok := r.GetAnnotations()[types.KeelURLAnnotation]
+	if !ok || url == "" {
+		return "", false
+	}
+	return url, true
+}
+
+// ContainerDrift - container image and the digest recorded when its tag was last resolved
+type ContainerDrift struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+	Init  bool   `json:"init"`
+	// RecordedDigest is empty when no digest was recorded for the container
+	RecordedDigest string `json:"recordedDigest"`
+}
+
+// ImageDriftReport - returns images of regular and init containers with their recorded
+// digests, taken from the per-container digest annotation or the resolved digests
+// annotation, in container order
+func (r *GenericResource) ImageDriftReport() []ContainerDrift {
+	resolved := r.GetResolvedDigests()
+	report := []ContainerDrift{}
+	for _, c := range r.containerRefs() {
+		digest, ok := r.GetTrackedDigest(c.Name)
+		if !ok {
+			digest = resolved[c.Name]
+		}
+		report = append(report, ContainerDrift{
+			Name:           c.Name,
+			Image:          c.Image,
+			Init:           c.Init,
+			RecordedDigest: digest,
+		})
+	}
+	return report
+}
+
+// FindContainersByImageSuffix - returns regular and init containers which normalized
+// repository (i.e. docker.io/library/nginx) equals the suffix or ends with "/" + suffix,
+// so matching is done on whole path segments: "myorg/app" matches docker.io/myorg/app
+// and gcr.io/myorg/app but not docker.io/othermyorg/app. Suffix must not include a tag.
+func (r *GenericResource) FindContainersByImageSuffix(suffix string) []ContainerRef {
+	matching := []ContainerRef{}
+	suffix = strings.Trim(suffix, "/")
+	if suffix == "" {
+		return matching
+	}
+	for _, c := range r.containerRefs() {
+		ref, err := image.Parse(c.Image)
+		if err != nil {
+			continue
+		}
+		repository := normalizedRepository(ref)
+		if repository == suffix || strings.HasSuffix(repository, "/"+suffix) {
+			matching = append(matching, c)
+		}
+	}
+	return matching
+}
+
+// SetContainerResources - sets resource requests and limits of the named regular or
+// init container, returns an error when the container isn't found
+func (r *GenericResource) SetContainerResources(name string, req core_v1.ResourceRequirements) error {
+	c, ok := r.getContainer(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, name)
+	}
+	c.Resources = *req.DeepCopy()
+	return nil
+}
+
+// GetUniqueImages - returns sorted fully qualified images of regular and init
+// containers without duplicates
+func (r *GenericResource) GetUniqueImages() []string {
+	seen := make(map[string]bool)
+	images := []string{}
+	for _, img := range r.GetNormalizedImages() {
+		if seen[img] {
+			continue
+		}
+		seen[img] = true
+		images = append(images, img)
+	}
+	sort.Strings(images)
+	return images
+}
+
+// SetContainerImageValidated - sets image of the container at index, the image is
+// rejected without changing the resource when it's not a valid image reference
+func (r *GenericResource) SetContainerImageValidated(index int, img string) error {
+	if err := r.checkContainerIndex(index); err != nil {
+		return err
+	}
+	if _, err := image.Parse(img); err != nil {
+		return fmt.Errorf("invalid image %q for container %s: %s", img, r.Containers()[index].Name, err)
+	}
+	r.UpdateContainer(index, img)
+	return nil
+}
+
+// LastAppliedConfig - returns configuration recorded by kubectl apply
+func (r *GenericResource) LastAppliedConfig() (string, bool) {
+	config, ok := r.GetAnnotations()[types.KubernetesLastAppliedConfigAnnotation]
+	return config, ok
+}
+
+// ClearLastAppliedConfig - removes configuration recorded by kubectl apply
+func (r *GenericResource) ClearLastAppliedConfig() {
+	annotations := r.GetAnnotations()
+	if _, ok := annotations[types.KubernetesLastAppliedConfigAnnotation]; !ok {
+		return
+	}
+	delete(annotations, types.KubernetesLastAppliedConfigAnnotation)
+	r.SetAnnotations(annotations)
+}
+
+// NextNumericTagContainers - returns regular and init containers running a numeric tag
+// (i.e. build number) lower than the latest value reported by the callback for their
+// fully qualified repository (i.e. docker.io/myorg/app). Containers with non-numeric
+// tags are skipped.
+func (r *GenericResource) NextNumericTagContainers(current func(repo string) int) []ContainerRef {
+	outdated := []ContainerRef{}
+	for _, c := range r.containerRefs() {
+		ref, err := image.Parse(c.Image)
+		if err != nil || isDigestReference(ref) {
+			continue
+		}
+		build, err := strconv.ParseUint(ref.Tag(), 10, 63)
+		if err != nil {
+			continue
+		}
+		if int64(build) < int64(current(normalizedRepository(ref))) {
+			outdated = append(outdated, c)
+		}
+	}
+	return outdated
+}
+
+// SetManifestListFlag - records whether image tag of the named container is a manifest list
+func (r *GenericResource) SetManifestListFlag(containerName string, isList bool) {
+	annotations := r.GetAnnotations()
+	annotations[types.KeelManifestListAnnotationPrefix+containerName] = strconv.FormatBool(isList)
+	r.SetAnnotations(annotations)
+}
+
+// GetManifestListFlag - returns whether image tag of the named container was recorded
+// as a manifest list, false when nothing was recorded or the value can't be parsed
+func (r *GenericResource) GetManifestListFlag(containerName string) (isList bool, ok bool) {
+	value, found := r.GetAnnotations()[types.KeelManifestListAnnotationPrefix+containerName]
+	if !found {
+		return false, false
+	}
+	isList, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, false
+	}
+	return isList, true
+}
+
+// DryRunUpdate - returns strategic merge patch the image updates (container name -> image)
+// would produce, the resource itself is not changed. Returns an error when any of the
+// named containers doesn't exist.
+func (r *GenericResource) DryRunUpdate(updates map[string]string) ([]byte, error) {
+	if err := r.checkContainerNames(updates); err != nil {
+		return nil, err
+	}
+
+	updated := r.DeepCopy()
+	updated.applyImages(updates)
+
+	patch, _, err := updated.MergePatchFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// rollout phases recorded by SetRolloutPhase
+const (
+	RolloutPhasePulling  = "pulling"
+	RolloutPhaseStarting = "starting"
+	RolloutPhaseReady    = "ready"
+	RolloutPhaseFailed   = "failed"
+)
+
+type rolloutPhase struct {
+	Phase string    `json:"phase"`
+	Time  time.Time `json:"time"`
+}
+
+// SetRolloutPhase - records rollout phase (one of RolloutPhasePulling, RolloutPhaseStarting,
+// RolloutPhaseReady or RolloutPhaseFailed) entered at t in the workload annotations
+func (r *GenericResource) SetRolloutPhase(phase string, t time.Time) {
+	encoded, err := json.Marshal(rolloutPhase{Phase: phase, Time: t.UTC()})
+	if err != nil {
+		return
+	}
+	annotations := r.GetAnnotations()
+	annotations[types.KeelRolloutPhaseAnnotation] = string(encoded)
+	r.SetAnnotations(annotations)
+}
+
+// GetRolloutPhase - returns recorded rollout phase and the time it was entered,
+// false when none was recorded or it can't be decoded
+func (r *GenericResource) GetRolloutPhase() (phase string, when time.Time, ok bool) {
+	value, found := r.GetAnnotations()[types.KeelRolloutPhaseAnnotation]
+	if !found {
+		return "", time.Time{}, false
+	}
+	var recorded rolloutPhase
+	if err := json.Unmarshal([]byte(value), &recorded); err != nil {
+		return "", time.Time{}, false
+	}
+	return recorded.Phase, recorded.Time, true
+}
+
+// HasImage - returns true when any regular or init container runs the image, images
+// are compared in their fully qualified form (nginx equals docker.io/library/nginx:latest)
+func (r *GenericResource) HasImage(img string) bool {
+	ref, err := image.Parse(img)
+	if err != nil {
+		return false
+	}
+	expected := normalizedImage(ref)
+	for _, current := range r.GetNormalizedImages() {
+		if current == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// HasImagePrefix - returns true when fully qualified image of any regular or init
+// container starts with the prefix (i.e. gcr.io/myorg/)
+func (r *GenericResource) HasImagePrefix(prefix string) bool {
+	for _, current := range r.GetNormalizedImages() {
+		if strings.HasPrefix(current, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerDigest - returns digest (i.e. sha256:...) the named container image is
+// pinned to, false when the image is not pinned by digest or container isn't found
+func (r *GenericResource) ContainerDigest(containerName string) (string, bool) {
+	c, ok := r.getContainer(containerName)
+	if !ok {
+		return "", false
+	}
+	ref, err := image.Parse(c.Image)
+	if err != nil || !isDigestReference(ref) {
+		return "", false
+	}
+	return ref.Tag(), true
+}
+
+// initContainerNamePrefix - prefix of init container names returned by ContainerNames,
+// container lookups by name accept it to refer to init containers only
+const initContainerNamePrefix = "init:"
+
+// updateKey - returns key of the updates (container name -> image) referring to the
+// container, init containers can be referred to with "init:" prefix or their bare name
+func updateKey(updates map[string]string, c ContainerRef) string {
+	if c.Init {
+		if _, ok := updates[initContainerNamePrefix+c.Name]; ok {
+			return initContainerNamePrefix + c.Name
+		}
+	}
+	return c.Name
+}
+
+// ContainerNames - returns names of regular containers followed by names of init
+// containers prefixed with "init:", both in spec order. Returned names are accepted
+// by all container lookups by name.
+func (r *GenericResource) ContainerNames() []string {
+	names := []string{}
+	for _, c := range r.containerRefs() {
+		if c.Init {
+			names = append(names, initContainerNamePrefix+c.Name)
+			continue
+		}
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// SetChangeCause - sets kubernetes.io/change-cause annotation on the pod template
+func (r *GenericResource) SetChangeCause(cause string) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return
+	}
+	annotations := getOrInitialise(template.GetAnnotations())
+	annotations[types.KubernetesChangeCauseAnnotation] = cause
+	template.SetAnnotations(annotations)
+}
+
+// GetChangeCause - returns kubernetes.io/change-cause annotation of the pod template
+func (r *GenericResource) GetChangeCause() (string, bool) {
+	template := r.getPodTemplate()
+	if template == nil {
+		return "", false
+	}
+	cause, ok := template.GetAnnotations()[types.KubernetesChangeCauseAnnotation]
+	return cause, ok
+}
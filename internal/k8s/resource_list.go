@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"sort"
+
+	"github.com/keel-hq/keel/util/image"
+)
+
+// ResourceList - list of generic resources
+type ResourceList []*GenericResource
+
+// ImageCountByNamespace - returns number of unique images used by the resources
+// in each namespace
+func ImageCountByNamespace(resources ResourceList) map[string]int {
+	images := make(map[string]map[string]bool)
+	for _, r := range resources {
+		if images[r.Namespace] == nil {
+			images[r.Namespace] = make(map[string]bool)
+		}
+		for _, img := range r.GetUniqueImages() {
+			images[r.Namespace][img] = true
+		}
+	}
+
+	counts := make(map[string]int)
+	for namespace, unique := range images {
+		counts[namespace] = len(unique)
+	}
+	return counts
+}
+
+// ResourcesUsingImage - returns resources running the image sorted by identifier. When
+// repoOnly is set any tag or digest of the image repository matches.
+func ResourcesUsingImage(resources ResourceList, img string, repoOnly bool) ResourceList {
+	matching := ResourceList{}
+
+	var repository string
+	if repoOnly {
+		ref, err := image.Parse(img)
+		if err != nil {
+			return matching
+		}
+		repository = normalizedRepository(ref)
+	}
+
+	for _, r := range resources {
+		var uses bool
+		if repoOnly {
+			uses = r.HasImagePrefix(repository+":") || r.HasImagePrefix(repository+"@")
+		} else {
+			uses = r.HasImage(img)
+		}
+		if uses {
+			matching = append(matching, r)
+		}
+	}
+
+	sort.Sort(genericResource(matching))
+	return matching
+}
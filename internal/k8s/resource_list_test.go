@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	apps_v1 "k8s.io/api/apps/v1"
+	core_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestDeployment(t *testing.T, namespace, name string, images ...string) *GenericResource {
+	var containers []core_v1.Container
+	for i, img := range images {
+		containers = append(containers, core_v1.Container{Name: fmt.Sprintf("%s-%d", name, i), Image: img})
+	}
+	gr, err := NewGenericResource(&apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: apps_v1.DeploymentSpec{
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{Containers: containers},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	return gr
+}
+
+func TestImageCountByNamespace(t *testing.T) {
+	resources := ResourceList{
+		newTestDeployment(t, "default", "web", "nginx:1.2", "docker.io/library/nginx:1.2", "envoy:1.1"),
+		newTestDeployment(t, "default", "api", "nginx:1.2", "karolisr/keel:0.2.0"),
+		newTestDeployment(t, "staging", "web", "nginx:1.2"),
+		newTestDeployment(t, "empty", "none"),
+	}
+
+	expected := map[string]int{
+		"default": 3,
+		"staging": 1,
+		"empty":   0,
+	}
+	if got := ImageCountByNamespace(resources); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected counts: %v", got)
+	}
+}
+
+func TestResourcesUsingImage(t *testing.T) {
+	web := newTestDeployment(t, "default", "web", "nginx:1.2")
+	api := newTestDeployment(t, "default", "api", "karolisr/keel:0.2.0", "docker.io/library/nginx:1.2")
+	legacy := newTestDeployment(t, "staging", "legacy", "nginx:1.1")
+	exporter := newTestDeployment(t, "default", "exporter", "nginx-exporter:1.2")
+	resources := ResourceList{web, legacy, exporter, api}
+
+	expected := ResourceList{api, web}
+	if got := ResourcesUsingImage(resources, "docker.io/nginx:1.2", false); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected resources: %v", got)
+	}
+
+	expected = ResourceList{api, web, legacy}
+	if got := ResourcesUsingImage(resources, "nginx", true); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected resources: %v", got)
+	}
+
+	if got := ResourcesUsingImage(resources, "redis:7", false); len(got) != 0 {
+		t.Errorf("expected no resources, got %v", got)
+	}
+}
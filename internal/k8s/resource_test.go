@@ -1,11 +1,21 @@
 package k8s
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/keel-hq/keel/types"
 
 	apps_v1 "k8s.io/api/apps/v1"
+	batch_v1 "k8s.io/api/batch/v1"
 	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8s_types "k8s.io/apimachinery/pkg/types"
 )
 
 func TestDeployment(t *testing.T) {
@@ -235,3 +245,2602 @@ func TestDaemonsetlSetMultipleContainers(t *testing.T) {
 		t.Errorf("unexpected image: %s", updated.Spec.Template.Spec.Containers[0].Image)
 	}
 }
+
+func TestGetActiveDeadlineSeconds(t *testing.T) {
+	deadline := int64(300)
+	cj := &batch_v1.CronJob{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cj-1",
+			Namespace: "xxxx",
+		},
+		Spec: batch_v1.CronJobSpec{
+			JobTemplate: batch_v1.JobTemplateSpec{
+				Spec: batch_v1.JobSpec{
+					ActiveDeadlineSeconds: &deadline,
+				},
+			},
+		},
+	}
+
+	gr, err := NewGenericResource(cj)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+
+	got := gr.GetActiveDeadlineSeconds()
+	if got == nil || *got != 300 {
+		t.Errorf("unexpected deadline: %v", got)
+	}
+
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "dep-1",
+			Namespace: "xxxx",
+		},
+	}
+	gr, err = NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	if gr.GetActiveDeadlineSeconds() != nil {
+		t.Errorf("expected nil deadline for deployment")
+	}
+}
+
+// newTestResources - builds one resource of every supported kind
+// sharing the given pod template
+func newTestResources(t *testing.T, template core_v1.PodTemplateSpec) []*GenericResource {
+	objs := []interface{}{
+		&apps_v1.Deployment{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx"},
+			Spec:       apps_v1.DeploymentSpec{Template: *template.DeepCopy()},
+		},
+		&apps_v1.StatefulSet{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "sts-1", Namespace: "xxxx"},
+			Spec:       apps_v1.StatefulSetSpec{Template: *template.DeepCopy()},
+		},
+		&apps_v1.DaemonSet{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "ds-1", Namespace: "xxxx"},
+			Spec:       apps_v1.DaemonSetSpec{Template: *template.DeepCopy()},
+		},
+		&batch_v1.CronJob{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "cj-1", Namespace: "xxxx"},
+			Spec: batch_v1.CronJobSpec{
+				JobTemplate: batch_v1.JobTemplateSpec{
+					Spec: batch_v1.JobSpec{Template: *template.DeepCopy()},
+				},
+			},
+		},
+	}
+
+	var resources []*GenericResource
+	for _, obj := range objs {
+		gr, err := NewGenericResource(obj)
+		if err != nil {
+			t.Fatalf("failed to create generic resource: %s", err)
+		}
+		resources = append(resources, gr)
+	}
+	return resources
+}
+
+func TestMergePatchFrom(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+		},
+	})
+
+	for _, original := range resources {
+		modified := original.DeepCopy()
+		modified.UpdateContainer(0, "gcr.io/v2-namespace/hello-world:1.1.2")
+
+		patch, patchType, err := modified.MergePatchFrom(original)
+		if err != nil {
+			t.Fatalf("%s: failed to create patch: %s", original.Kind(), err)
+		}
+
+		if patchType != k8s_types.StrategicMergePatchType {
+			t.Errorf("%s: unexpected patch type: %s", original.Kind(), patchType)
+		}
+
+		if !strings.Contains(string(patch), "hello-world:1.1.2") {
+			t.Errorf("%s: patch doesn't contain new image: %s", original.Kind(), string(patch))
+		}
+
+		if strings.Contains(string(patch), original.Name) {
+			t.Errorf("%s: patch contains unchanged fields: %s", original.Kind(), string(patch))
+		}
+	}
+
+	if _, _, err := resources[0].MergePatchFrom(resources[3]); err == nil {
+		t.Errorf("expected an error when patching between different kinds")
+	}
+}
+
+func TestGetImageID(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers:     []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+			InitContainers: []core_v1.Container{{Name: "init", Image: "gcr.io/v2-namespace/init:1.0.0"}},
+		},
+	})
+
+	for _, gr := range resources {
+		if id, ok := gr.GetImageID("app"); !ok || id != "gcr.io/v2-namespace/hello-world:1.1.1" {
+			t.Errorf("%s: unexpected image id: %s", gr.Kind(), id)
+		}
+		if id, ok := gr.GetImageID("init"); !ok || id != "gcr.io/v2-namespace/init:1.0.0" {
+			t.Errorf("%s: unexpected init image id: %s", gr.Kind(), id)
+		}
+		if _, ok := gr.GetImageID("missing"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestGetLabelsAndAnnotationsWithPrefix(t *testing.T) {
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "dep-1",
+			Namespace: "xxxx",
+			Labels: map[string]string{
+				"team.example.com/owner": "payments",
+				"team.example.com/tier":  "backend",
+				"app":                    "api",
+			},
+			Annotations: map[string]string{
+				"keel.sh/policy":  "major",
+				"keel.sh/trigger": "poll",
+				"other":           "value",
+			},
+		},
+	}
+
+	gr, err := NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+
+	labels := gr.GetLabelsWithPrefix("team.example.com/")
+	if len(labels) != 2 || labels["team.example.com/owner"] != "payments" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+
+	annotations := gr.GetAnnotationsWithPrefix("keel.sh/")
+	if len(annotations) != 2 || annotations["keel.sh/policy"] != "major" {
+		t.Errorf("unexpected annotations: %v", annotations)
+	}
+
+	if none := gr.GetLabelsWithPrefix("missing/"); len(none) != 0 {
+		t.Errorf("expected no labels, got: %v", none)
+	}
+}
+
+func TestPodTemplateLabels(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Labels: map[string]string{"app": "hello"},
+		},
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+		},
+	})
+
+	for _, gr := range resources {
+		labels := gr.GetPodTemplateLabels()
+		if labels["app"] != "hello" {
+			t.Errorf("%s: unexpected labels: %v", gr.Kind(), labels)
+		}
+
+		labels["restart"] = "1"
+		gr.SetPodTemplateLabels(labels)
+
+		if gr.GetPodTemplateLabels()["restart"] != "1" {
+			t.Errorf("%s: label wasn't set on the pod template", gr.Kind())
+		}
+		if _, ok := gr.GetLabels()["restart"]; ok {
+			t.Errorf("%s: label shouldn't be set on the workload", gr.Kind())
+		}
+	}
+}
+
+func TestNewGenericResourceFromYAML(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: wd
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+      - name: wd
+        image: keelhq/push-workflow-example:0.1.0
+`
+	gr, err := NewGenericResourceFromYAML([]byte(manifest))
+	if err != nil {
+		t.Fatalf("failed to decode manifest: %s", err)
+	}
+
+	if gr.Identifier != "deployment/default/wd" {
+		t.Errorf("unexpected identifier: %s", gr.Identifier)
+	}
+	if images := gr.GetImages(); len(images) != 1 || images[0] != "keelhq/push-workflow-example:0.1.0" {
+		t.Errorf("unexpected images: %v", images)
+	}
+
+	service := `
+apiVersion: v1
+kind: Service
+metadata:
+  name: wd
+`
+	_, err = NewGenericResourceFromYAML([]byte(service))
+	if !errors.Is(err, ErrUnsupportedResource) {
+		t.Errorf("expected unsupported resource error, got: %v", err)
+	}
+
+	_, err = NewGenericResourceFromYAML([]byte("kind: [not yaml"))
+	if err == nil || errors.Is(err, ErrUnsupportedResource) {
+		t.Errorf("expected decode error, got: %v", err)
+	}
+}
+
+func TestGetContainerResources(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{
+					Name:  "app",
+					Image: "gcr.io/v2-namespace/hello-world:1.1.1",
+					Resources: core_v1.ResourceRequirements{
+						Requests: core_v1.ResourceList{core_v1.ResourceCPU: resource.MustParse("100m")},
+						Limits:   core_v1.ResourceList{core_v1.ResourceMemory: resource.MustParse("128Mi")},
+					},
+				},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		req, ok := gr.GetContainerResources("app")
+		if !ok {
+			t.Fatalf("%s: container not found", gr.Kind())
+		}
+		if cpu := req.Requests[core_v1.ResourceCPU]; cpu.String() != "100m" {
+			t.Errorf("%s: unexpected cpu request: %s", gr.Kind(), cpu.String())
+		}
+
+		// modifying the copy must not affect the resource
+		req.Requests[core_v1.ResourceCPU] = resource.MustParse("1")
+		again, _ := gr.GetContainerResources("app")
+		if cpu := again.Requests[core_v1.ResourceCPU]; cpu.String() != "100m" {
+			t.Errorf("%s: resources weren't copied", gr.Kind())
+		}
+
+		if _, ok := gr.GetContainerResources("missing"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestKindPredicates(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{})
+
+	expected := []struct {
+		deployment, statefulset, daemonset, cronjob bool
+	}{
+		{true, false, false, false},
+		{false, true, false, false},
+		{false, false, true, false},
+		{false, false, false, true},
+	}
+
+	for i, gr := range resources {
+		got := []bool{gr.IsDeployment(), gr.IsStatefulSet(), gr.IsDaemonSet(), gr.IsCronJob()}
+		want := []bool{expected[i].deployment, expected[i].statefulset, expected[i].daemonset, expected[i].cronjob}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Errorf("%s: unexpected predicates: %v, expected: %v", gr.Kind(), got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestImageInventory(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "sidecar", Image: "gcr.io/v2-namespace/sidecar:0.1.0"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "migrate", Image: "gcr.io/v2-namespace/migrate:2.0.0"},
+			},
+		},
+	})
+
+	expected := []ContainerImage{
+		{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+		{Name: "sidecar", Image: "gcr.io/v2-namespace/sidecar:0.1.0"},
+		{Name: "migrate", Image: "gcr.io/v2-namespace/migrate:2.0.0", Init: true},
+	}
+
+	for _, gr := range resources {
+		inventory := gr.ImageInventory()
+		if !reflect.DeepEqual(inventory, expected) {
+			t.Errorf("%s: unexpected inventory: %v", gr.Kind(), inventory)
+		}
+	}
+}
+
+func TestImagePullSecrets(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{})
+
+	for _, gr := range resources {
+		gr.AddImagePullSecret("registry-a")
+		gr.AddImagePullSecret("registry-b")
+		gr.AddImagePullSecret("registry-a")
+
+		secrets := gr.GetImagePullSecrets()
+		if !reflect.DeepEqual(secrets, []string{"registry-a", "registry-b"}) {
+			t.Errorf("%s: unexpected secrets: %v", gr.Kind(), secrets)
+		}
+
+		gr.SetImagePullSecrets([]string{"registry-c"})
+		secrets = gr.GetImagePullSecrets()
+		if !reflect.DeepEqual(secrets, []string{"registry-c"}) {
+			t.Errorf("%s: unexpected secrets: %v", gr.Kind(), secrets)
+		}
+	}
+}
+
+func TestIsPaused(t *testing.T) {
+	for _, paused := range []bool{true, false} {
+		d := &apps_v1.Deployment{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx"},
+			Spec:       apps_v1.DeploymentSpec{Paused: paused},
+		}
+		gr, err := NewGenericResource(d)
+		if err != nil {
+			t.Fatalf("failed to create generic resource: %s", err)
+		}
+		if gr.IsPaused() != paused {
+			t.Errorf("expected paused to be %t", paused)
+		}
+	}
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{})[1:] {
+		if gr.IsPaused() {
+			t.Errorf("%s: expected not to be paused", gr.Kind())
+		}
+	}
+}
+
+func TestGetContainerEnvValue(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{
+					Name:  "app",
+					Image: "gcr.io/v2-namespace/hello-world:1.1.1",
+					Env: []core_v1.EnvVar{
+						{Name: "APP_VERSION", Value: "1.1.1"},
+						{Name: "SECRET", ValueFrom: &core_v1.EnvVarSource{
+							SecretKeyRef: &core_v1.SecretKeySelector{Key: "password"},
+						}},
+					},
+				},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		if val, ok := gr.GetContainerEnvValue("app", "APP_VERSION"); !ok || val != "1.1.1" {
+			t.Errorf("%s: unexpected value: %s", gr.Kind(), val)
+		}
+		if _, ok := gr.GetContainerEnvValue("app", "SECRET"); ok {
+			t.Errorf("%s: referenced values shouldn't be returned", gr.Kind())
+		}
+		if _, ok := gr.GetContainerEnvValue("app", "MISSING"); ok {
+			t.Errorf("%s: expected missing key not to be found", gr.Kind())
+		}
+		if _, ok := gr.GetContainerEnvValue("missing", "APP_VERSION"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestStampUpdateTime(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{})
+	now := time.Date(2023, 4, 1, 12, 30, 0, 1000, time.UTC)
+
+	for _, gr := range resources {
+		if _, ok := gr.LastUpdateTime(); ok {
+			t.Errorf("%s: didn't expect update time", gr.Kind())
+		}
+
+		gr.StampUpdateTime(now)
+
+		got, ok := gr.LastUpdateTime()
+		if !ok || !got.Equal(now) {
+			t.Errorf("%s: unexpected update time: %s", gr.Kind(), got)
+		}
+
+		// force updates within the same second must still change the template
+		hash, _ := gr.HashPodTemplate()
+		gr.StampUpdateTime(now.Add(time.Millisecond))
+		if changed, _ := gr.HashPodTemplate(); changed == hash {
+			t.Errorf("%s: expected stamp within the same second to change the pod template", gr.Kind())
+		}
+		if _, ok := gr.GetSpecAnnotations()[types.KeelUpdateTimeAnnotation]; ok && gr.IsCronJob() {
+			t.Errorf("%s: didn't expect update time on the job template metadata", gr.Kind())
+		}
+	}
+
+	cj, ok := resources[3].GetResource().(*batch_v1.CronJob)
+	if !ok {
+		t.Fatalf("conversion failed")
+	}
+	if _, ok := cj.Spec.JobTemplate.Spec.Template.Annotations[types.KeelUpdateTimeAnnotation]; !ok {
+		t.Errorf("expected update time on the cronjob pod template")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx"},
+		Spec: apps_v1.DeploymentSpec{
+			Selector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"app": "hello"}},
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+				},
+			},
+		},
+	}
+	gr, err := NewGenericResource(valid)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	if err := gr.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+
+	invalid := valid.DeepCopy()
+	invalid.Spec.Selector = nil
+	invalid.Spec.Template.Spec.Containers = append(invalid.Spec.Template.Spec.Containers, core_v1.Container{})
+	gr, err = NewGenericResource(invalid)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+
+	err = gr.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got: %v", err)
+	}
+	// missing name, missing image, missing selector
+	if len(errs) != 3 {
+		t.Errorf("unexpected validation errors: %s", errs)
+	}
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if err := gr.Validate(); err == nil {
+			t.Errorf("%s: expected an error for resource without containers", gr.Kind())
+		}
+	}
+}
+
+func TestGVK(t *testing.T) {
+	expected := []schema.GroupVersionKind{
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+		{Group: "batch", Version: "v1", Kind: "CronJob"},
+	}
+
+	for i, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if gvk := gr.GVK(); gvk != expected[i] {
+			t.Errorf("%s: unexpected gvk: %s", gr.Kind(), gvk)
+		}
+	}
+}
+
+func TestEstimatedReadyTimeout(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{
+					Name:  "app",
+					Image: "gcr.io/v2-namespace/hello-world:1.1.1",
+					StartupProbe: &core_v1.Probe{
+						PeriodSeconds:    5,
+						FailureThreshold: 12,
+					},
+					ReadinessProbe: &core_v1.Probe{
+						InitialDelaySeconds: 10,
+					},
+				},
+				{
+					Name:  "sidecar",
+					Image: "gcr.io/v2-namespace/sidecar:0.1.0",
+					ReadinessProbe: &core_v1.Probe{
+						InitialDelaySeconds: 20,
+						PeriodSeconds:       5,
+						FailureThreshold:    2,
+					},
+				},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		// app: 5*12 + 10 + 10*3
+		if timeout := gr.EstimatedReadyTimeout(); timeout != 100*time.Second {
+			t.Errorf("%s: unexpected timeout: %s", gr.Kind(), timeout)
+		}
+	}
+
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx"},
+		Spec: apps_v1.DeploymentSpec{
+			MinReadySeconds: 15,
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+				},
+			},
+		},
+	}
+	gr, err := NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	if timeout := gr.EstimatedReadyTimeout(); timeout != DefaultReadyTimeout+15*time.Second {
+		t.Errorf("unexpected timeout: %s", timeout)
+	}
+}
+
+func TestGetRevisionHistoryLimit(t *testing.T) {
+	limit := int32(2)
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx"},
+		Spec:       apps_v1.DeploymentSpec{RevisionHistoryLimit: &limit},
+	}
+	gr, err := NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	if got := gr.GetRevisionHistoryLimit(); got == nil || *got != 2 {
+		t.Errorf("unexpected limit: %v", got)
+	}
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if got := gr.GetRevisionHistoryLimit(); got != nil {
+			t.Errorf("%s: expected nil limit, got: %d", gr.Kind(), *got)
+		}
+	}
+}
+
+func TestMergeAnnotationsFrom(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Annotations: map[string]string{"a": "1"},
+		},
+	})
+
+	for _, gr := range resources {
+		gr.SetAnnotations(map[string]string{"keel.sh/policy": "major", "a": "1"})
+
+		latest := gr.DeepCopy()
+		latest.SetAnnotations(map[string]string{"keel.sh/policy": "minor", "keel.sh/trigger": "poll", "a": "2", "b": "3"})
+		latestTemplate := latest.getPodTemplate()
+		latestTemplate.SetAnnotations(map[string]string{"a": "2", "c": "4"})
+
+		gr.MergeAnnotationsFrom(latest)
+		expected := map[string]string{"keel.sh/policy": "major", "a": "1", "b": "3"}
+		if annotations := gr.GetAnnotations(); !reflect.DeepEqual(annotations, expected) {
+			t.Errorf("%s: unexpected annotations: %v", gr.Kind(), annotations)
+		}
+
+		gr.MergePodTemplateAnnotationsFrom(latest)
+		expected = map[string]string{"a": "1", "c": "4"}
+		if annotations := gr.getPodTemplate().GetAnnotations(); !reflect.DeepEqual(annotations, expected) {
+			t.Errorf("%s: unexpected pod template annotations: %v", gr.Kind(), annotations)
+		}
+	}
+}
+
+func TestImagesByRegistry(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "proxy", Image: "nginx"},
+				{Name: "other-proxy", Image: "nginx:1.19"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/init:1.0.0"},
+				{Name: "wait", Image: "karolisr/wait:latest"},
+			},
+		},
+	})
+
+	expected := map[string][]string{
+		"gcr.io":    {"gcr.io/v2-namespace/hello-world", "gcr.io/v2-namespace/init"},
+		"docker.io": {"docker.io/karolisr/wait", "docker.io/library/nginx"},
+	}
+
+	for _, gr := range resources {
+		if grouped := gr.ImagesByRegistry(); !reflect.DeepEqual(grouped, expected) {
+			t.Errorf("%s: unexpected images: %v", gr.Kind(), grouped)
+		}
+	}
+}
+
+func TestNeedsUpdate(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers:     []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+			InitContainers: []core_v1.Container{{Name: "init", Image: "gcr.io/v2-namespace/init:1.0.0"}},
+		},
+	})
+
+	tests := []struct {
+		desired map[string]string
+		want    bool
+	}{
+		{map[string]string{"app": "gcr.io/v2-namespace/hello-world:1.1.1"}, false},
+		{map[string]string{"app": "gcr.io/v2-namespace/hello-world:1.1.2"}, true},
+		{map[string]string{"init": "gcr.io/v2-namespace/init:1.0.1"}, true},
+		{map[string]string{"missing": "gcr.io/v2-namespace/other:1.0.0"}, false},
+		{nil, false},
+	}
+
+	for _, gr := range resources {
+		for _, tt := range tests {
+			if got := gr.NeedsUpdate(tt.desired); got != tt.want {
+				t.Errorf("%s: NeedsUpdate(%v) = %t, want %t", gr.Kind(), tt.desired, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestScaleTargetRef(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{})
+
+	apiVersion, kind, name, ok := resources[0].ScaleTargetRef()
+	if !ok || apiVersion != "apps/v1" || kind != "Deployment" || name != "dep-1" {
+		t.Errorf("unexpected scale target ref: %s %s %s %t", apiVersion, kind, name, ok)
+	}
+
+	for _, gr := range resources[2:] {
+		if _, _, _, ok := gr.ScaleTargetRef(); ok {
+			t.Errorf("%s: didn't expect scale target ref", gr.Kind())
+		}
+	}
+}
+
+func TestGetNormalizedImages(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "a", Image: "nginx"},
+				{Name: "b", Image: "nginx:1.19"},
+				{Name: "c", Image: "library/nginx"},
+				{Name: "d", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "e", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	expected := []string{
+		"docker.io/library/nginx:latest",
+		"docker.io/library/nginx:1.19",
+		"docker.io/library/nginx:latest",
+		"gcr.io/v2-namespace/hello-world:1.1.1",
+		"docker.io/karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f",
+	}
+
+	for _, gr := range resources {
+		if images := gr.GetNormalizedImages(); !reflect.DeepEqual(images, expected) {
+			t.Errorf("%s: unexpected images: %v", gr.Kind(), images)
+		}
+		if images := gr.GetImages(); images[0] != "nginx" {
+			t.Errorf("%s: original images shouldn't change: %v", gr.Kind(), images)
+		}
+	}
+}
+
+func TestUpdateSource(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if source := gr.GetUpdateSource(); source != "" {
+			t.Errorf("%s: unexpected source: %s", gr.Kind(), source)
+		}
+
+		before, _ := gr.getPodTemplate().Marshal()
+		gr.SetUpdateSource("webhook")
+		if source := gr.GetUpdateSource(); source != "webhook" {
+			t.Errorf("%s: unexpected source: %s", gr.Kind(), source)
+		}
+
+		after, _ := gr.getPodTemplate().Marshal()
+		if string(before) != string(after) {
+			t.Errorf("%s: pod template shouldn't change", gr.Kind())
+		}
+	}
+}
+
+func TestGetContainerTag(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "tagged", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "untagged", Image: "nginx"},
+				{Name: "registry-port", Image: "localhost:5000/app:2.0.0"},
+				{Name: "tag-digest", Image: "localhost:5000/app:2.0.0@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "digest", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	expected := map[string]string{
+		"tagged":        "1.1.1",
+		"untagged":      "latest",
+		"registry-port": "2.0.0",
+		"tag-digest":    "2.0.0",
+		"digest":        "",
+	}
+
+	for _, gr := range resources {
+		for name, want := range expected {
+			if tag, ok := gr.GetContainerTag(name); !ok || tag != want {
+				t.Errorf("%s: unexpected tag of %s: %s", gr.Kind(), name, tag)
+			}
+		}
+		if _, ok := gr.GetContainerTag("missing"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	replicas := int32(3)
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "dep-1",
+			Namespace:   "xxxx",
+			Annotations: map[string]string{types.KeelPolicyLabel: "major"},
+		},
+		Spec: apps_v1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: core_v1.PodTemplateSpec{
+				Spec: core_v1.PodSpec{
+					Containers:     []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+					InitContainers: []core_v1.Container{{Name: "init", Image: "gcr.io/v2-namespace/init:1.0.0"}},
+				},
+			},
+		},
+		Status: apps_v1.DeploymentStatus{ReadyReplicas: 2},
+	}
+
+	gr, err := NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+
+	expected := ResourceReport{
+		Kind:            "deployment",
+		Namespace:       "xxxx",
+		Name:            "dep-1",
+		Identifier:      "deployment/xxxx/dep-1",
+		ImageCount:      2,
+		ReadyReplicas:   2,
+		DesiredReplicas: 3,
+		Managed:         true,
+	}
+	if report := gr.Report(); report != expected {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	gr.SetAnnotations(map[string]string{})
+	if gr.Report().Managed {
+		t.Errorf("expected resource without policy not to be managed")
+	}
+}
+
+func TestSetContainerImageIfChanged(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+		},
+	})
+
+	for _, gr := range resources {
+		changed, err := gr.SetContainerImageIfChanged(0, "gcr.io/v2-namespace/hello-world:1.1.1")
+		if err != nil || changed {
+			t.Errorf("%s: expected no change, got: %t, %v", gr.Kind(), changed, err)
+		}
+
+		changed, err = gr.SetContainerImageIfChanged(0, "gcr.io/v2-namespace/hello-world:1.1.2")
+		if err != nil || !changed {
+			t.Errorf("%s: expected change, got: %t, %v", gr.Kind(), changed, err)
+		}
+		if img := gr.Containers()[0].Image; img != "gcr.io/v2-namespace/hello-world:1.1.2" {
+			t.Errorf("%s: unexpected image: %s", gr.Kind(), img)
+		}
+
+		if _, err := gr.SetContainerImageIfChanged(1, "gcr.io/v2-namespace/hello-world:1.1.2"); !errors.Is(err, ErrContainerIndexOutOfRange) {
+			t.Errorf("%s: expected out of range error, got: %v", gr.Kind(), err)
+		}
+	}
+}
+
+func TestCloneToNamespace(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		original := gr.GetResource().(meta_v1.Object)
+		original.SetResourceVersion("123")
+		original.SetUID("abc")
+
+		clone := gr.CloneToNamespace("production")
+
+		if clone.Namespace != "production" || clone.GetNamespace() != "production" {
+			t.Errorf("%s: unexpected namespace: %s", gr.Kind(), clone.Namespace)
+		}
+		if clone.Identifier != gr.Kind()+"/production/"+gr.Name {
+			t.Errorf("%s: unexpected identifier: %s", gr.Kind(), clone.Identifier)
+		}
+
+		meta := clone.GetResource().(meta_v1.Object)
+		if meta.GetResourceVersion() != "" || meta.GetUID() != "" {
+			t.Errorf("%s: expected resource version and uid to be cleared", gr.Kind())
+		}
+
+		if gr.Namespace != "xxxx" || original.GetResourceVersion() != "123" {
+			t.Errorf("%s: original resource shouldn't change", gr.Kind())
+		}
+	}
+}
+
+func TestRepositories(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "proxy", Image: "nginx"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "docker.io/library/nginx:1.19"},
+				{Name: "wait", Image: "karolisr/wait@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	expected := []string{
+		"docker.io/karolisr/wait",
+		"docker.io/library/nginx",
+		"gcr.io/v2-namespace/hello-world",
+	}
+
+	for _, gr := range resources {
+		if repositories := gr.Repositories(); !reflect.DeepEqual(repositories, expected) {
+			t.Errorf("%s: unexpected repositories: %v", gr.Kind(), repositories)
+		}
+	}
+}
+
+func TestContainersWithStaleDigest(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:latest"},
+				{Name: "current", Image: "gcr.io/v2-namespace/hello-world:latest"},
+				{Name: "untracked", Image: "gcr.io/v2-namespace/hello-world:latest"},
+				{Name: "other-tag", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/init"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		gr.SetTrackedDigest("app", "sha256:old")
+		gr.SetTrackedDigest("current", "sha256:new")
+		gr.SetTrackedDigest("other-tag", "sha256:old")
+		gr.SetTrackedDigest("init", "sha256:old")
+
+		if digest, ok := gr.GetTrackedDigest("app"); !ok || digest != "sha256:old" {
+			t.Errorf("%s: unexpected tracked digest: %s", gr.Kind(), digest)
+		}
+
+		stale := gr.ContainersWithStaleDigest("latest", "sha256:new")
+		if !reflect.DeepEqual(stale, []string{"app", "init"}) {
+			t.Errorf("%s: unexpected stale containers: %v", gr.Kind(), stale)
+		}
+	}
+}
+
+func TestDesiredPods(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{})
+
+	for i, want := range []int32{1, 1, 0, 0} {
+		if got := resources[i].DesiredPods(); got != want {
+			t.Errorf("%s: unexpected desired pods: %d, want %d", resources[i].Kind(), got, want)
+		}
+	}
+
+	replicas := int32(5)
+	resources[0].GetResource().(*apps_v1.Deployment).Spec.Replicas = &replicas
+	resources[1].GetResource().(*apps_v1.StatefulSet).Spec.Replicas = &replicas
+	resources[2].GetResource().(*apps_v1.DaemonSet).Status.DesiredNumberScheduled = 4
+
+	for i, want := range []int32{5, 5, 4, 0} {
+		if got := resources[i].DesiredPods(); got != want {
+			t.Errorf("%s: unexpected desired pods: %d, want %d", resources[i].Kind(), got, want)
+		}
+	}
+}
+
+func TestEffectivePullPolicy(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "latest", Image: "gcr.io/v2-namespace/hello-world:latest"},
+				{Name: "untagged", Image: "nginx"},
+				{Name: "versioned", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "latest-digest", Image: "nginx:latest@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "explicit", Image: "gcr.io/v2-namespace/init:latest", ImagePullPolicy: core_v1.PullNever},
+			},
+		},
+	})
+
+	expected := map[string]core_v1.PullPolicy{
+		"latest":        core_v1.PullAlways,
+		"untagged":      core_v1.PullAlways,
+		"versioned":     core_v1.PullIfNotPresent,
+		"latest-digest": core_v1.PullAlways,
+		"explicit":      core_v1.PullNever,
+	}
+
+	for _, gr := range resources {
+		for name, want := range expected {
+			if got, ok := gr.EffectivePullPolicy(name); !ok || got != want {
+				t.Errorf("%s: unexpected pull policy of %s: %s", gr.Kind(), name, got)
+			}
+		}
+		if _, ok := gr.EffectivePullPolicy("missing"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestMergeLabelsAndAnnotations(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		gr.SetAnnotations(map[string]string{"other.tool/id": "1", types.KeelPolicyLabel: "major"})
+		gr.MergeAnnotations(map[string]string{types.KeelPolicyLabel: "minor", types.KeelTriggerLabel: "poll"})
+
+		expected := map[string]string{"other.tool/id": "1", types.KeelPolicyLabel: "minor", types.KeelTriggerLabel: "poll"}
+		if annotations := gr.GetAnnotations(); !reflect.DeepEqual(annotations, expected) {
+			t.Errorf("%s: unexpected annotations: %v", gr.Kind(), annotations)
+		}
+
+		// labels are nil initially
+		gr.MergeLabels(map[string]string{"app": "hello"})
+		gr.MergeLabels(map[string]string{"team": "payments"})
+
+		expected = map[string]string{"app": "hello", "team": "payments"}
+		if labels := gr.GetLabels(); !reflect.DeepEqual(labels, expected) {
+			t.Errorf("%s: unexpected labels: %v", gr.Kind(), labels)
+		}
+	}
+}
+
+func TestIsContainerDigestPinned(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "pinned", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+				{Name: "floating", Image: "karolisr/keel:latest"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		if pinned, ok := gr.IsContainerDigestPinned("pinned"); !ok || !pinned {
+			t.Errorf("%s: expected container to be pinned", gr.Kind())
+		}
+		if pinned, ok := gr.IsContainerDigestPinned("floating"); !ok || pinned {
+			t.Errorf("%s: expected container not to be pinned", gr.Kind())
+		}
+		if _, ok := gr.IsContainerDigestPinned("missing"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestImageUsageCounts(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "a", Image: "nginx"},
+				{Name: "b", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "c", Image: "docker.io/library/nginx:latest"},
+			},
+		},
+	})
+
+	expected := map[string]int{
+		"docker.io/library/nginx:latest":        2,
+		"gcr.io/v2-namespace/hello-world:1.1.1": 1,
+	}
+
+	for _, gr := range resources {
+		if counts := gr.ImageUsageCounts(); !reflect.DeepEqual(counts, expected) {
+			t.Errorf("%s: unexpected counts: %v", gr.Kind(), counts)
+		}
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		gr.SetPolicy("major")
+		if p := gr.PolicyAnnotation(); p != "major" {
+			t.Errorf("%s: unexpected policy: %s", gr.Kind(), p)
+		}
+		if !gr.Report().Managed {
+			t.Errorf("%s: expected resource to be managed", gr.Kind())
+		}
+
+		gr.ClearPolicy()
+		if p := gr.PolicyAnnotation(); p != "" {
+			t.Errorf("%s: unexpected policy: %s", gr.Kind(), p)
+		}
+		if gr.Report().Managed {
+			t.Errorf("%s: expected resource not to be managed", gr.Kind())
+		}
+
+		gr.SetPolicy("major")
+		gr.SetLabels(map[string]string{types.KeelPolicyLabel: "minor"})
+		gr.ClearPolicy()
+		if p := gr.PolicyAnnotation(); p != "" {
+			t.Errorf("%s: unexpected policy: %s", gr.Kind(), p)
+		}
+		if l := gr.GetLabels()[types.KeelPolicyLabel]; l != "minor" {
+			t.Errorf("%s: policy label must be preserved, got %q", gr.Kind(), l)
+		}
+	}
+}
+
+func TestAggregateResources(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{
+					Name: "app",
+					Resources: core_v1.ResourceRequirements{
+						Requests: core_v1.ResourceList{
+							core_v1.ResourceCPU:    resource.MustParse("100m"),
+							core_v1.ResourceMemory: resource.MustParse("128Mi"),
+						},
+						Limits: core_v1.ResourceList{core_v1.ResourceCPU: resource.MustParse("200m")},
+					},
+				},
+				{
+					Name: "sidecar",
+					Resources: core_v1.ResourceRequirements{
+						Requests: core_v1.ResourceList{
+							core_v1.ResourceCPU:    resource.MustParse("50m"),
+							core_v1.ResourceMemory: resource.MustParse("64Mi"),
+						},
+					},
+				},
+			},
+			InitContainers: []core_v1.Container{
+				{
+					Name: "migrate",
+					Resources: core_v1.ResourceRequirements{
+						Requests: core_v1.ResourceList{
+							core_v1.ResourceCPU:    resource.MustParse("500m"),
+							core_v1.ResourceMemory: resource.MustParse("64Mi"),
+						},
+					},
+				},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		requests, limits := gr.AggregateResources()
+
+		if cpu := requests[core_v1.ResourceCPU]; cpu.Cmp(resource.MustParse("500m")) != 0 {
+			t.Errorf("%s: unexpected cpu request: %s", gr.Kind(), cpu.String())
+		}
+		if mem := requests[core_v1.ResourceMemory]; mem.Cmp(resource.MustParse("192Mi")) != 0 {
+			t.Errorf("%s: unexpected memory request: %s", gr.Kind(), mem.String())
+		}
+		if cpu := limits[core_v1.ResourceCPU]; cpu.Cmp(resource.MustParse("200m")) != 0 {
+			t.Errorf("%s: unexpected cpu limit: %s", gr.Kind(), cpu.String())
+		}
+		if len(limits) != 1 {
+			t.Errorf("%s: unexpected limits: %v", gr.Kind(), limits)
+		}
+	}
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		requests, limits := gr.AggregateResources()
+		if requests == nil || limits == nil || len(requests) != 0 || len(limits) != 0 {
+			t.Errorf("%s: expected empty lists", gr.Kind())
+		}
+	}
+}
+
+func TestFinalizers(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if gr.HasFinalizer("keel.sh/rollout") {
+			t.Errorf("%s: unexpected finalizer", gr.Kind())
+		}
+
+		gr.RemoveFinalizer("keel.sh/rollout")
+		gr.AddFinalizer("keel.sh/rollout")
+		gr.AddFinalizer("keel.sh/rollout")
+		gr.AddFinalizer("other")
+
+		meta := gr.GetResource().(meta_v1.Object)
+		if !reflect.DeepEqual(meta.GetFinalizers(), []string{"keel.sh/rollout", "other"}) {
+			t.Errorf("%s: unexpected finalizers: %v", gr.Kind(), meta.GetFinalizers())
+		}
+
+		gr.RemoveFinalizer("keel.sh/rollout")
+		if gr.HasFinalizer("keel.sh/rollout") || !gr.HasFinalizer("other") {
+			t.Errorf("%s: unexpected finalizers: %v", gr.Kind(), meta.GetFinalizers())
+		}
+	}
+}
+
+func TestTouch(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		before, err := gr.getPodTemplate().Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal pod template: %s", err)
+		}
+
+		gr.Touch(time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC))
+
+		after, err := gr.getPodTemplate().Marshal()
+		if err != nil {
+			t.Fatalf("failed to marshal pod template: %s", err)
+		}
+		if string(before) == string(after) {
+			t.Errorf("%s: expected pod template to change", gr.Kind())
+		}
+		if v := gr.getPodTemplate().Annotations[types.KubernetesRestartedAtAnnotation]; v != "2023-04-01T12:30:00Z" {
+			t.Errorf("%s: unexpected restartedAt: %s", gr.Kind(), v)
+		}
+	}
+}
+
+func TestGetPullHint(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		gr.SetAnnotations(map[string]string{
+			"keel.sh/pull-hint.app":    "2m30s",
+			"keel.sh/pull-hint.broken": "soon",
+		})
+
+		if d, ok := gr.GetPullHint("app"); !ok || d != 150*time.Second {
+			t.Errorf("%s: unexpected pull hint: %s", gr.Kind(), d)
+		}
+		if _, ok := gr.GetPullHint("broken"); ok {
+			t.Errorf("%s: expected unparseable hint to be ignored", gr.Kind())
+		}
+		if _, ok := gr.GetPullHint("missing"); ok {
+			t.Errorf("%s: expected missing hint not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestContainersSorted(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "web", Image: "gcr.io/v2-namespace/web:1.0.0"},
+				{Name: "app", Image: "gcr.io/v2-namespace/app:1.0.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		sorted := gr.ContainersSorted()
+		if len(sorted) != 2 || sorted[0].Name != "app" || sorted[1].Name != "web" {
+			t.Errorf("%s: unexpected order: %v", gr.Kind(), sorted)
+		}
+
+		sorted[0].Image = "changed"
+		if containers := gr.Containers(); containers[0].Name != "web" || containers[1].Image != "gcr.io/v2-namespace/app:1.0.0" {
+			t.Errorf("%s: live containers shouldn't change: %v", gr.Kind(), containers)
+		}
+	}
+}
+
+func TestRebaseImages(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "registry.old.io:5000/team/app:1.1.1"},
+				{Name: "other", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "hub", Image: "team/api"},
+				{Name: "sibling", Image: "registry.old.io:5000/teams/app:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "registry.old.io:5000/team/init@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		if changed := gr.RebaseImages("registry.old.io:5000/team/", "registry.new.io/team/"); changed != 2 {
+			t.Errorf("%s: unexpected number of changes: %d", gr.Kind(), changed)
+		}
+		if changed := gr.RebaseImages("docker.io/team/", "registry.new.io/team/"); changed != 1 {
+			t.Errorf("%s: unexpected number of changes: %d", gr.Kind(), changed)
+		}
+
+		expected := []string{
+			"registry.new.io/team/app:1.1.1",
+			"gcr.io/v2-namespace/hello-world:1.1.1",
+			"registry.new.io/team/api",
+			"registry.old.io:5000/teams/app:1.1.1",
+			"registry.new.io/team/init@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f",
+		}
+		if images := append(gr.GetImages(), gr.GetInitImages()...); !reflect.DeepEqual(images, expected) {
+			t.Errorf("%s: unexpected images: %v", gr.Kind(), images)
+		}
+
+		// prefix must match whole path segments
+		if changed := gr.RebaseImages("registry.old.io:5000/team", "registry.new.io/team"); changed != 0 {
+			t.Errorf("%s: expected sibling repository not to be rebased, got %d changes", gr.Kind(), changed)
+		}
+	}
+}
+
+func TestValidateImageUpdate(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:2.1.1"}},
+		},
+	})
+
+	for _, gr := range resources {
+		if err := gr.ValidateImageUpdate(0, "gcr.io/v2-namespace/hello-world:2.2.0"); err != nil {
+			t.Errorf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if err := gr.ValidateImageUpdate(0, "gcr.io/v2-namespace/hello-world:1.9.0"); err == nil {
+			t.Errorf("%s: expected major downgrade to be rejected", gr.Kind())
+		}
+		if err := gr.ValidateImageUpdate(0, "gcr.io/v2-namespace/other:2.2.0"); err == nil {
+			t.Errorf("%s: expected repository change to be rejected", gr.Kind())
+		}
+		if err := gr.ValidateImageUpdate(1, "gcr.io/v2-namespace/hello-world:2.2.0"); !errors.Is(err, ErrContainerIndexOutOfRange) {
+			t.Errorf("%s: expected out of range error, got: %v", gr.Kind(), err)
+		}
+
+		gr.SetAnnotations(map[string]string{types.KeelAllowRepositoryChangeAnnotation: "true"})
+		if err := gr.ValidateImageUpdate(0, "gcr.io/v2-namespace/other:2.2.0"); err != nil {
+			t.Errorf("%s: expected repository change to be allowed: %s", gr.Kind(), err)
+		}
+	}
+}
+
+func TestEligibleContainers(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "sidecar", Image: "gcr.io/v2-namespace/sidecar:0.1.0"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+		},
+	})
+
+	expected := []ContainerRef{
+		{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1", Index: 0},
+		{Name: "init", Image: "gcr.io/v2-namespace/hello-world:1.1.1", Index: 0, Init: true},
+	}
+
+	for _, gr := range resources {
+		eligible := gr.EligibleContainers(func(name, img string) bool {
+			return strings.Contains(img, "hello-world")
+		})
+		if !reflect.DeepEqual(eligible, expected) {
+			t.Errorf("%s: unexpected containers: %+v", gr.Kind(), eligible)
+		}
+	}
+}
+
+func TestRolloutMessage(t *testing.T) {
+	d := &apps_v1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "dep-1", Namespace: "xxxx"},
+		Status: apps_v1.DeploymentStatus{
+			Conditions: []apps_v1.DeploymentCondition{
+				{
+					Type:    apps_v1.DeploymentProgressing,
+					Status:  core_v1.ConditionFalse,
+					Reason:  "ProgressDeadlineExceeded",
+					Message: `ReplicaSet "dep-1-abc" has timed out progressing.`,
+				},
+			},
+		},
+	}
+	gr, err := NewGenericResource(d)
+	if err != nil {
+		t.Fatalf("failed to create generic resource: %s", err)
+	}
+	if msg, ok := gr.RolloutMessage(); !ok || !strings.HasPrefix(msg, "rollout failed: ProgressDeadlineExceeded") {
+		t.Errorf("unexpected message: %s", msg)
+	}
+
+	resources := newTestResources(t, core_v1.PodTemplateSpec{})
+	ss := resources[1].GetResource().(*apps_v1.StatefulSet)
+	ss.Status = apps_v1.StatefulSetStatus{Replicas: 3, UpdatedReplicas: 1, ReadyReplicas: 2}
+	if msg, ok := resources[1].RolloutMessage(); !ok || msg != "1 of 3 replicas updated, 2 ready" {
+		t.Errorf("unexpected message: %s", msg)
+	}
+
+	if _, ok := resources[3].RolloutMessage(); ok {
+		t.Errorf("didn't expect a message for cronjob")
+	}
+}
+
+func TestOutdatedContainers(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "proxy", Image: "nginx:1.19.0"},
+				{Name: "floating", Image: "gcr.io/v2-namespace/floating:latest"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/init:2.0.0"},
+			},
+		},
+	})
+
+	latest := map[string]string{
+		"gcr.io/v2-namespace/hello-world": "1.2.0",
+		"docker.io/library/nginx":         "1.19.0",
+		"gcr.io/v2-namespace/floating":    "1.0.0",
+		"gcr.io/v2-namespace/init":        "2.0.1",
+	}
+
+	expected := []OutdatedContainer{
+		{Name: "app", Current: "1.1.1", Available: "1.2.0"},
+		{Name: "init", Current: "2.0.0", Available: "2.0.1"},
+	}
+
+	for _, gr := range resources {
+		outdated := gr.OutdatedContainers(func(repo string) (string, bool) {
+			tag, ok := latest[repo]
+			return tag, ok
+		})
+		if !reflect.DeepEqual(outdated, expected) {
+			t.Errorf("%s: unexpected outdated containers: %+v", gr.Kind(), outdated)
+		}
+	}
+}
+
+func TestResolveUpdateTarget(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "proxy", Image: "nginx:1.19"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		ref, ok := gr.ResolveUpdateTarget("gcr.io/v2-namespace/hello-world", false)
+		if !ok || ref.Name != "app" || ref.Init {
+			t.Errorf("%s: unexpected target: %+v", gr.Kind(), ref)
+		}
+
+		ref, ok = gr.ResolveUpdateTarget("gcr.io/v2-namespace/hello-world:1.1.2", true)
+		if !ok || ref.Name != "init" || !ref.Init {
+			t.Errorf("%s: unexpected target: %+v", gr.Kind(), ref)
+		}
+
+		ref, ok = gr.ResolveUpdateTarget("docker.io/library/nginx", true)
+		if !ok || ref.Name != "proxy" || ref.Index != 1 {
+			t.Errorf("%s: unexpected target: %+v", gr.Kind(), ref)
+		}
+
+		if _, ok := gr.ResolveUpdateTarget("gcr.io/v2-namespace/other", false); ok {
+			t.Errorf("%s: didn't expect a target", gr.Kind())
+		}
+	}
+}
+
+func TestEnsurePullAlwaysForTags(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "latest", Image: "gcr.io/v2-namespace/hello-world"},
+				{Name: "main", Image: "gcr.io/v2-namespace/app:main", ImagePullPolicy: core_v1.PullIfNotPresent},
+				{Name: "versioned", Image: "gcr.io/v2-namespace/app:1.1.1"},
+				{Name: "always", Image: "gcr.io/v2-namespace/app:latest", ImagePullPolicy: core_v1.PullAlways},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/init:main"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		if changed := gr.EnsurePullAlwaysForTags([]string{"latest", "main"}); changed != 3 {
+			t.Errorf("%s: unexpected number of changes: %d", gr.Kind(), changed)
+		}
+		for _, name := range []string{"latest", "main", "always", "init"} {
+			if policy, _ := gr.EffectivePullPolicy(name); policy != core_v1.PullAlways {
+				t.Errorf("%s: unexpected pull policy of %s: %s", gr.Kind(), name, policy)
+			}
+		}
+		if c, _ := gr.getContainer("versioned"); c.ImagePullPolicy != "" {
+			t.Errorf("%s: versioned container shouldn't change", gr.Kind())
+		}
+		if changed := gr.EnsurePullAlwaysForTags([]string{"latest", "main"}); changed != 0 {
+			t.Errorf("%s: expected no changes, got: %d", gr.Kind(), changed)
+		}
+	}
+}
+
+func TestContainerTagsAreSemver(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "semver", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "prefixed", Image: "gcr.io/v2-namespace/hello-world:v2.0.0-rc1"},
+				{Name: "latest", Image: "gcr.io/v2-namespace/hello-world"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "digest", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	expected := map[string]bool{
+		"semver":   true,
+		"prefixed": true,
+		"latest":   false,
+		"digest":   false,
+	}
+
+	for _, gr := range resources {
+		if got := gr.ContainerTagsAreSemver(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected result: %v", gr.Kind(), got)
+		}
+	}
+}
+
+func TestIsSuspended(t *testing.T) {
+	suspended, active := true, false
+	for _, tc := range []struct {
+		suspend  *bool
+		expected bool
+	}{
+		{suspend: &suspended, expected: true},
+		{suspend: &active, expected: false},
+		{suspend: nil, expected: false},
+	} {
+		cj := &batch_v1.CronJob{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "cj-1", Namespace: "xxxx"},
+			Spec:       batch_v1.CronJobSpec{Suspend: tc.suspend},
+		}
+		gr, err := NewGenericResource(cj)
+		if err != nil {
+			t.Fatalf("failed to create generic resource: %s", err)
+		}
+		if gr.IsSuspended() != tc.expected {
+			t.Errorf("expected suspended to be %t", tc.expected)
+		}
+	}
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{})[:3] {
+		if gr.IsSuspended() {
+			t.Errorf("%s: expected not to be suspended", gr.Kind())
+		}
+	}
+}
+
+func TestContainerImageTagOnly(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "pinned", Image: "gcr.io/v2-namespace/hello-world:1.1.1@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+				{Name: "tagged", Image: "localhost:5000/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "digest", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	expected := map[string]string{
+		"pinned": "gcr.io/v2-namespace/hello-world:1.1.1",
+		"tagged": "localhost:5000/hello-world:1.1.1",
+	}
+
+	for _, gr := range resources {
+		for name, want := range expected {
+			got, ok := gr.ContainerImageTagOnly(name)
+			if !ok {
+				t.Fatalf("%s: expected container %s to be found", gr.Kind(), name)
+			}
+			if got != want {
+				t.Errorf("%s: expected %s, got %s", gr.Kind(), want, got)
+			}
+		}
+		if _, ok := gr.ContainerImageTagOnly("missing"); ok {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+		if got, ok := gr.ContainerImageTagOnly("digest"); ok {
+			t.Errorf("%s: digest only image has no tag to keep, got %s", gr.Kind(), got)
+		}
+	}
+}
+
+func TestPullFailure(t *testing.T) {
+	observed := time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if _, _, ok := gr.GetPullFailure("app"); ok {
+			t.Errorf("%s: expected no pull failure", gr.Kind())
+		}
+
+		gr.SetPullFailure("app", "ImagePullBackOff", observed)
+
+		reason, when, ok := gr.GetPullFailure("app")
+		if !ok {
+			t.Fatalf("%s: expected pull failure to be recorded", gr.Kind())
+		}
+		if reason != "ImagePullBackOff" {
+			t.Errorf("%s: unexpected reason: %s", gr.Kind(), reason)
+		}
+		if !when.Equal(observed) {
+			t.Errorf("%s: unexpected time: %s", gr.Kind(), when)
+		}
+		if _, ok := gr.getPodTemplate().Annotations[types.KeelPullFailureAnnotationPrefix+"app"]; ok {
+			t.Errorf("%s: pull failure must not be set on the pod template", gr.Kind())
+		}
+
+		gr.SetAnnotations(map[string]string{types.KeelPullFailureAnnotationPrefix + "app": "not-json"})
+		if _, _, ok := gr.GetPullFailure("app"); ok {
+			t.Errorf("%s: expected malformed pull failure to be ignored", gr.Kind())
+		}
+	}
+}
+
+func TestParseImages(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "broken", Image: "Invalid/Image:1.0"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:0.2.0"},
+				{Name: "empty", Image: ""},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		refs, errs := gr.ParseImages()
+
+		if len(refs) != 2 {
+			t.Fatalf("%s: expected 2 references, got %d", gr.Kind(), len(refs))
+		}
+		if refs[0].Container != "app" || refs[0].Init || refs[0].Reference.Remote() != "gcr.io/v2-namespace/hello-world:1.1.1" {
+			t.Errorf("%s: unexpected reference: %+v", gr.Kind(), refs[0])
+		}
+		if refs[1].Container != "init" || !refs[1].Init || refs[1].Reference.Tag() != "0.2.0" {
+			t.Errorf("%s: unexpected reference: %+v", gr.Kind(), refs[1])
+		}
+
+		if len(errs) != 2 {
+			t.Fatalf("%s: expected 2 errors, got %d", gr.Kind(), len(errs))
+		}
+		var parseErr *ImageParseError
+		if !errors.As(errs[0], &parseErr) || parseErr.Container != "broken" || parseErr.Image != "Invalid/Image:1.0" {
+			t.Errorf("%s: unexpected error: %v", gr.Kind(), errs[0])
+		}
+		if !errors.As(errs[1], &parseErr) || parseErr.Container != "empty" {
+			t.Errorf("%s: unexpected error: %v", gr.Kind(), errs[1])
+		}
+	}
+}
+
+func TestPreviewUpdate(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "web", Image: "nginx:1.2"},
+				{Name: "sidecar", Image: "envoy:1.0"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "migrate", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		preview, err := gr.PreviewUpdate(map[string]string{
+			"migrate": "karolisr/keel:0.3.0",
+			"web":     "nginx:1.3",
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		expected := "web: nginx:1.2 -> nginx:1.3\nmigrate: karolisr/keel:0.2.0 -> karolisr/keel:0.3.0"
+		if preview != expected {
+			t.Errorf("%s: unexpected preview:\n%s", gr.Kind(), preview)
+		}
+
+		_, err = gr.PreviewUpdate(map[string]string{
+			"web":   "nginx:1.3",
+			"db":    "postgres:15",
+			"cache": "redis:7",
+		})
+		if !errors.Is(err, ErrContainerNotFound) || !strings.Contains(err.Error(), "cache, db, available containers: web, sidecar, init:migrate") {
+			t.Errorf("%s: unexpected error: %v", gr.Kind(), err)
+		}
+
+		_, err = gr.PreviewUpdate(map[string]string{
+			"migrate":      "karolisr/keel:0.3.0",
+			"init:migrate": "karolisr/keel:0.4.0",
+		})
+		if err == nil || err.Error() != "container names init:migrate and migrate refer to the same container" {
+			t.Errorf("%s: unexpected error: %v", gr.Kind(), err)
+		}
+	}
+}
+
+func TestForceUpdateImage(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:latest"},
+			},
+		},
+	})
+	stamped := time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, gr := range resources {
+		if err := gr.ForceUpdateImage(0, "gcr.io/v2-namespace/hello-world:latest", stamped); err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if gr.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:latest" {
+			t.Errorf("%s: unexpected image: %s", gr.Kind(), gr.Containers()[0].Image)
+		}
+		if got, ok := gr.LastUpdateTime(); !ok || !got.Equal(stamped) {
+			t.Errorf("%s: expected update time to be stamped, got %s", gr.Kind(), got)
+		}
+
+		err := gr.ForceUpdateImage(1, "gcr.io/v2-namespace/hello-world:1.1.1", stamped.Add(time.Hour))
+		if !errors.Is(err, ErrContainerIndexOutOfRange) {
+			t.Errorf("%s: expected out of range error, got %v", gr.Kind(), err)
+		}
+		if got, _ := gr.LastUpdateTime(); !got.Equal(stamped) {
+			t.Errorf("%s: update time must not change on failed update", gr.Kind())
+		}
+	}
+}
+
+func TestCompareContainerTag(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "build", Image: "gcr.io/v2-namespace/hello-world:build-b"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:v0.2.0"},
+			},
+		},
+	})
+
+	tests := []struct {
+		container string
+		candidate string
+		want      int
+		notSemver bool
+	}{
+		{container: "app", candidate: "1.2.0", want: -1},
+		{container: "app", candidate: "1.1.1", want: 0},
+		{container: "app", candidate: "1.0.10", want: 1},
+		{container: "init", candidate: "0.2.1", want: -1},
+		{container: "build", candidate: "build-a", want: 1, notSemver: true},
+		{container: "app", candidate: "latest", want: -1, notSemver: true},
+	}
+
+	for _, gr := range resources {
+		for _, tt := range tests {
+			got, err := gr.CompareContainerTag(tt.container, tt.candidate)
+			if got != tt.want {
+				t.Errorf("%s: %s vs %s: expected %d, got %d", gr.Kind(), tt.container, tt.candidate, tt.want, got)
+			}
+			if errors.Is(err, ErrTagNotSemver) != tt.notSemver {
+				t.Errorf("%s: %s vs %s: unexpected error: %v", gr.Kind(), tt.container, tt.candidate, err)
+			}
+		}
+
+		if _, err := gr.CompareContainerTag("missing", "1.0.0"); !errors.Is(err, ErrContainerNotFound) {
+			t.Errorf("%s: expected container not found error, got %v", gr.Kind(), err)
+		}
+	}
+}
+
+func TestContainersMatchingTagGlob(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:release-1.1"},
+				{Name: "sidecar", Image: "gcr.io/v2-namespace/sidecar:dev-1.1"},
+				{Name: "pinned", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:release-0.2"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		matching, err := gr.ContainersMatchingTagGlob("release-*")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		expected := []ContainerRef{
+			{Name: "app", Image: "gcr.io/v2-namespace/hello-world:release-1.1", Index: 0},
+			{Name: "init", Image: "karolisr/keel:release-0.2", Index: 0, Init: true},
+		}
+		if !reflect.DeepEqual(matching, expected) {
+			t.Errorf("%s: unexpected containers: %+v", gr.Kind(), matching)
+		}
+
+		if matching, _ := gr.ContainersMatchingTagGlob("*"); len(matching) != 3 {
+			t.Errorf("%s: expected all tagged containers to match, got %+v", gr.Kind(), matching)
+		}
+
+		if _, err := gr.ContainersMatchingTagGlob(""); err == nil {
+			t.Errorf("%s: expected error for empty pattern", gr.Kind())
+		}
+	}
+}
+
+func TestResolvedDigests(t *testing.T) {
+	digests := map[string]string{
+		"app":  "sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f",
+		"init": "sha256:1e5f3f0f2b5f7d2e1f7e0d4f6e8de7b6e2ce0f9f2f2f5b8f1d7c4b3c2d1e0f9a",
+	}
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if got := gr.GetResolvedDigests(); len(got) != 0 {
+			t.Errorf("%s: expected no digests, got %v", gr.Kind(), got)
+		}
+
+		gr.SetResolvedDigests(nil)
+		if _, ok := gr.GetAnnotations()[types.KeelResolvedDigestsAnnotation]; ok {
+			t.Errorf("%s: empty digests must not be written", gr.Kind())
+		}
+
+		gr.SetResolvedDigests(digests)
+		if got := gr.GetResolvedDigests(); !reflect.DeepEqual(got, digests) {
+			t.Errorf("%s: unexpected digests: %v", gr.Kind(), got)
+		}
+
+		gr.SetResolvedDigests(map[string]string{})
+		if _, ok := gr.GetAnnotations()[types.KeelResolvedDigestsAnnotation]; ok {
+			t.Errorf("%s: expected annotation to be removed", gr.Kind())
+		}
+
+		gr.SetAnnotations(map[string]string{types.KeelResolvedDigestsAnnotation: "not-json"})
+		if got := gr.GetResolvedDigests(); len(got) != 0 {
+			t.Errorf("%s: expected malformed annotation to be ignored, got %v", gr.Kind(), got)
+		}
+	}
+}
+
+func TestEnsureLabel(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		gr.SetLabels(nil)
+
+		if !gr.EnsureLabel("keel.sh/managed", "true") {
+			t.Errorf("%s: expected label to be set", gr.Kind())
+		}
+		if gr.EnsureLabel("keel.sh/managed", "true") {
+			t.Errorf("%s: expected no change for the same value", gr.Kind())
+		}
+		if !gr.EnsureLabel("keel.sh/managed", "false") {
+			t.Errorf("%s: expected label value to be changed", gr.Kind())
+		}
+		if v := gr.GetLabels()["keel.sh/managed"]; v != "false" {
+			t.Errorf("%s: unexpected label value: %s", gr.Kind(), v)
+		}
+
+		if !gr.RemoveLabel("keel.sh/managed") {
+			t.Errorf("%s: expected label to be removed", gr.Kind())
+		}
+		if gr.RemoveLabel("keel.sh/managed") {
+			t.Errorf("%s: expected no change for missing label", gr.Kind())
+		}
+		if _, ok := gr.GetLabels()["keel.sh/managed"]; ok {
+			t.Errorf("%s: label must not be present", gr.Kind())
+		}
+	}
+}
+
+func TestExplainEligibility(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "pinned", Image: "karolisr/keel@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	newer := func(current string) (string, bool) {
+		return "gcr.io/v2-namespace/hello-world:1.1.2", true
+	}
+	same := func(current string) (string, bool) {
+		return current, true
+	}
+	excluded := func(current string) (string, bool) {
+		return "", false
+	}
+
+	tests := []struct {
+		index      int
+		policy     func(current string) (string, bool)
+		wantUpdate string
+		wantReason string
+	}{
+		{index: 0, policy: newer, wantUpdate: "gcr.io/v2-namespace/hello-world:1.1.2", wantReason: EligibilityEligible},
+		{index: 0, policy: same, wantReason: EligibilityNoNewerTag},
+		{index: 0, policy: excluded, wantReason: EligibilityPolicyExcluded},
+		{index: 1, policy: newer, wantReason: EligibilityDigestPinned},
+		{index: 2, policy: newer, wantReason: EligibilityInvalidIndex},
+		{index: -1, policy: newer, wantReason: EligibilityInvalidIndex},
+	}
+
+	for _, gr := range resources {
+		for _, tt := range tests {
+			update, reason := gr.ExplainEligibility(tt.index, tt.policy)
+			if update != tt.wantUpdate || reason != tt.wantReason {
+				t.Errorf("%s: container %d: expected %q/%s, got %q/%s", gr.Kind(), tt.index, tt.wantUpdate, tt.wantReason, update, reason)
+			}
+		}
+	}
+}
+
+func TestRebaseOnto(t *testing.T) {
+	template := core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	}
+	updated := newTestResources(t, template)
+	live := newTestResources(t, template)
+
+	for i, gr := range updated {
+		gr.UpdateContainer(0, "gcr.io/v2-namespace/hello-world:1.1.2")
+		gr.UpdateInitContainer(0, "karolisr/keel:0.3.0")
+
+		l := live[i]
+		l.SetLabels(map[string]string{"team": "platform"})
+		l.SetAnnotations(map[string]string{"deployment.kubernetes.io/revision": "3"})
+		l.getObjectMeta().ResourceVersion = "42"
+
+		if err := gr.RebaseOnto(l); err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if gr.getObjectMeta().ResourceVersion != "42" {
+			t.Errorf("%s: expected live resource version", gr.Kind())
+		}
+		if gr.GetLabels()["team"] != "platform" || gr.GetAnnotations()["deployment.kubernetes.io/revision"] != "3" {
+			t.Errorf("%s: expected live metadata to be preserved", gr.Kind())
+		}
+		if !reflect.DeepEqual(gr.GetImages(), []string{"gcr.io/v2-namespace/hello-world:1.1.2"}) ||
+			!reflect.DeepEqual(gr.GetInitImages(), []string{"karolisr/keel:0.3.0"}) {
+			t.Errorf("%s: expected images to be re-applied, got %v %v", gr.Kind(), gr.GetImages(), gr.GetInitImages())
+		}
+		if l.GetImages()[0] != "gcr.io/v2-namespace/hello-world:1.1.1" {
+			t.Errorf("%s: live resource must not be modified", gr.Kind())
+		}
+	}
+
+	if err := updated[0].RebaseOnto(live[1]); err == nil {
+		t.Errorf("expected error for different kinds")
+	}
+
+	other := live[0].DeepCopy()
+	other.getObjectMeta().Name = "dep-2"
+	if err := updated[0].RebaseOnto(other); err == nil || !strings.Contains(err.Error(), "dep-2") {
+		t.Errorf("expected error for different resource, got %v", err)
+	}
+}
+
+func TestRebaseOntoChangedContainers(t *testing.T) {
+	updated := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "sidecar", Image: "envoy:1.1"},
+			},
+		},
+	})
+	live := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "logger", Image: "fluentd:1.0"},
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+		},
+	})
+
+	for i, gr := range updated {
+		gr.UpdateContainer(0, "gcr.io/v2-namespace/hello-world:1.1.2")
+		gr.UpdateContainer(1, "envoy:1.2")
+
+		if err := gr.RebaseOnto(live[i]); err == nil || !strings.Contains(err.Error(), "sidecar") {
+			t.Errorf("%s: expected error about removed container, got %v", gr.Kind(), err)
+		}
+		if gr.GetImages()[1] != "envoy:1.2" {
+			t.Errorf("%s: receiver must not change on error", gr.Kind())
+		}
+
+		// unchanged containers missing in live are not an error
+		gr.UpdateContainer(1, "envoy:1.1")
+		if err := gr.RebaseOnto(live[i]); err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		expected := []string{"fluentd:1.0", "gcr.io/v2-namespace/hello-world:1.1.2"}
+		if !reflect.DeepEqual(gr.GetImages(), expected) {
+			t.Errorf("%s: unexpected images: %v", gr.Kind(), gr.GetImages())
+		}
+	}
+}
+
+func TestRebaseOntoPreservesLiveImageChanges(t *testing.T) {
+	template := core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "sidecar", Image: "envoy:1.1"},
+			},
+		},
+	}
+	updated := newTestResources(t, template)
+	live := newTestResources(t, template)
+
+	for i, gr := range updated {
+		gr.UpdateContainer(0, "gcr.io/v2-namespace/hello-world:1.1.2")
+		// sidecar updated by someone else since the receiver was read
+		live[i].UpdateContainer(1, "envoy:1.2")
+
+		if err := gr.RebaseOnto(live[i]); err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		expected := []string{"gcr.io/v2-namespace/hello-world:1.1.2", "envoy:1.2"}
+		if !reflect.DeepEqual(gr.GetImages(), expected) {
+			t.Errorf("%s: unexpected images: %v", gr.Kind(), gr.GetImages())
+		}
+	}
+}
+
+func TestContainerPolicies(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "sidecar", Image: "envoy:1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		expected := map[string]string{"app": "", "sidecar": "", "init": ""}
+		if got := gr.ContainerPolicies(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected policies: %v", gr.Kind(), got)
+		}
+
+		gr.SetLabels(map[string]string{"keel.observer/policy": "patch"})
+		expected = map[string]string{"app": "patch", "sidecar": "patch", "init": "patch"}
+		if got := gr.ContainerPolicies(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected legacy policy label to be used, got %v", gr.Kind(), got)
+		}
+
+		gr.SetLabels(map[string]string{types.KeelPolicyLabel: "major"})
+		gr.SetAnnotations(map[string]string{
+			types.KeelContainerPolicyAnnotationPrefix + "init": "force",
+		})
+		expected = map[string]string{"app": "major", "sidecar": "major", "init": "force"}
+		if got := gr.ContainerPolicies(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected policies: %v", gr.Kind(), got)
+		}
+
+		gr.SetAnnotations(map[string]string{
+			types.KeelPolicyAnnotation:                            "minor",
+			types.KeelContainerPolicyAnnotationPrefix + "sidecar": "patch",
+		})
+		expected = map[string]string{"app": "minor", "sidecar": "patch", "init": "minor"}
+		if got := gr.ContainerPolicies(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected policies: %v", gr.Kind(), got)
+		}
+	}
+}
+
+func TestUsesRegistry(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "nginx:1.2"},
+			},
+		},
+	})
+
+	tests := []struct {
+		registry string
+		want     bool
+	}{
+		{registry: "gcr.io", want: true},
+		{registry: "docker.io", want: true},
+		{registry: "index.docker.io", want: true},
+		{registry: "quay.io", want: false},
+		{registry: "localhost:5000", want: false},
+	}
+
+	for _, gr := range resources {
+		for _, tt := range tests {
+			if got := gr.UsesRegistry(tt.registry); got != tt.want {
+				t.Errorf("%s: %s: expected %t, got %t", gr.Kind(), tt.registry, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestForceUpdateImages(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "sidecar", Image: "envoy:1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	})
+	stamped := time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, gr := range resources {
+		_, err := gr.ForceUpdateImages(map[string]string{
+			"app":     "gcr.io/v2-namespace/hello-world:1.1.2",
+			"missing": "nginx:1.3",
+		}, stamped)
+		if !errors.Is(err, ErrContainerNotFound) {
+			t.Errorf("%s: expected container not found error, got %v", gr.Kind(), err)
+		}
+		if gr.GetImages()[0] != "gcr.io/v2-namespace/hello-world:1.1.1" {
+			t.Errorf("%s: resource must not change on error", gr.Kind())
+		}
+		if _, ok := gr.LastUpdateTime(); ok {
+			t.Errorf("%s: update time must not be stamped on error", gr.Kind())
+		}
+
+		changed, err := gr.ForceUpdateImages(map[string]string{
+			"init":    "karolisr/keel:0.3.0",
+			"sidecar": "envoy:1.1",
+			"app":     "gcr.io/v2-namespace/hello-world:1.1.2",
+		}, stamped)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if !reflect.DeepEqual(changed, []string{"app", "init"}) {
+			t.Errorf("%s: unexpected changed containers: %v", gr.Kind(), changed)
+		}
+		if !reflect.DeepEqual(gr.GetImages(), []string{"gcr.io/v2-namespace/hello-world:1.1.2", "envoy:1.1"}) ||
+			!reflect.DeepEqual(gr.GetInitImages(), []string{"karolisr/keel:0.3.0"}) {
+			t.Errorf("%s: unexpected images: %v %v", gr.Kind(), gr.GetImages(), gr.GetInitImages())
+		}
+		if got, ok := gr.LastUpdateTime(); !ok || !got.Equal(stamped) {
+			t.Errorf("%s: expected update time to be stamped, got %s", gr.Kind(), got)
+		}
+	}
+}
+
+func TestContainerTagEquals(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "web", Image: "nginx:1.2@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/hello-world"},
+				{Name: "pinned", Image: "nginx@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"},
+			},
+		},
+	})
+
+	tests := []struct {
+		container string
+		image     string
+		want      bool
+	}{
+		{container: "pinned", image: "nginx:latest", want: false},
+		{container: "pinned", image: "nginx@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f", want: false},
+		{container: "web", image: "nginx@sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f", want: false},
+		{container: "web", image: "nginx:1.2", want: true},
+		{container: "web", image: "docker.io/library/nginx:1.2@sha256:1e5f3f0f2b5f7d2e1f7e0d4f6e8de7b6e2ce0f9f2f2f5b8f1d7c4b3c2d1e0f9a", want: true},
+		{container: "web", image: "nginx:1.3", want: false},
+		{container: "web", image: "gcr.io/nginx:1.2", want: false},
+		{container: "init", image: "gcr.io/v2-namespace/hello-world:latest", want: true},
+	}
+
+	for _, gr := range resources {
+		for _, tt := range tests {
+			equal, found := gr.ContainerTagEquals(tt.container, tt.image)
+			if !found {
+				t.Fatalf("%s: expected container %s to be found", gr.Kind(), tt.container)
+			}
+			if equal != tt.want {
+				t.Errorf("%s: %s vs %s: expected %t", gr.Kind(), tt.container, tt.image, tt.want)
+			}
+		}
+		if _, found := gr.ContainerTagEquals("missing", "nginx:1.2"); found {
+			t.Errorf("%s: expected missing container not to be found", gr.Kind())
+		}
+	}
+}
+
+func TestExternalURL(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if _, ok := gr.ExternalURL(); ok {
+			t.Errorf("%s: expected no url", gr.Kind())
+		}
+
+		gr.SetAnnotations(map[string]string{types.KeelURLAnnotation: "https://app.example.com"})
+		if url, ok := gr.ExternalURL(); !ok || url != "https://app.example.com" {
+			t.Errorf("%s: unexpected url: %s", gr.Kind(), url)
+		}
+	}
+}
+
+func TestImageDriftReport(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:latest"},
+				{Name: "sidecar", Image: "envoy:1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:latest"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		gr.SetTrackedDigest("app", "sha256:aaa")
+		gr.SetResolvedDigests(map[string]string{"app": "sha256:bbb", "init": "sha256:ccc"})
+
+		expected := []ContainerDrift{
+			{Name: "app", Image: "gcr.io/v2-namespace/hello-world:latest", RecordedDigest: "sha256:aaa"},
+			{Name: "sidecar", Image: "envoy:1.1"},
+			{Name: "init", Image: "karolisr/keel:latest", Init: true, RecordedDigest: "sha256:ccc"},
+		}
+		if got := gr.ImageDriftReport(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected report: %+v", gr.Kind(), got)
+		}
+	}
+}
+
+func TestFindContainersByImageSuffix(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "myorg/app:1.1.1"},
+				{Name: "other", Image: "othermyorg/app:1.1.1"},
+				{Name: "web", Image: "nginx:1.2"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/myorg/app:1.0.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		expected := []ContainerRef{
+			{Name: "app", Image: "myorg/app:1.1.1", Index: 0},
+			{Name: "init", Image: "gcr.io/myorg/app:1.0.0", Index: 0, Init: true},
+		}
+		if got := gr.FindContainersByImageSuffix("myorg/app"); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected containers: %+v", gr.Kind(), got)
+		}
+
+		if got := gr.FindContainersByImageSuffix("library/nginx"); len(got) != 1 || got[0].Name != "web" {
+			t.Errorf("%s: unexpected containers: %+v", gr.Kind(), got)
+		}
+		if got := gr.FindContainersByImageSuffix("docker.io/library/nginx"); len(got) != 1 || got[0].Name != "web" {
+			t.Errorf("%s: unexpected containers: %+v", gr.Kind(), got)
+		}
+		if got := gr.FindContainersByImageSuffix(""); len(got) != 0 {
+			t.Errorf("%s: expected no containers for empty suffix, got %+v", gr.Kind(), got)
+		}
+	}
+}
+
+func TestSetContainerResources(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	})
+
+	req := core_v1.ResourceRequirements{
+		Requests: core_v1.ResourceList{core_v1.ResourceCPU: resource.MustParse("250m")},
+		Limits:   core_v1.ResourceList{core_v1.ResourceMemory: resource.MustParse("256Mi")},
+	}
+
+	for _, gr := range resources {
+		for _, name := range []string{"app", "init"} {
+			if err := gr.SetContainerResources(name, req); err != nil {
+				t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+			}
+			got, _ := gr.GetContainerResources(name)
+			if cpu := got.Requests[core_v1.ResourceCPU]; cpu.String() != "250m" {
+				t.Errorf("%s: %s: unexpected cpu request: %s", gr.Kind(), name, cpu.String())
+			}
+			if mem := got.Limits[core_v1.ResourceMemory]; mem.String() != "256Mi" {
+				t.Errorf("%s: %s: unexpected memory limit: %s", gr.Kind(), name, mem.String())
+			}
+		}
+
+		if err := gr.SetContainerResources("missing", req); !errors.Is(err, ErrContainerNotFound) {
+			t.Errorf("%s: expected container not found error, got %v", gr.Kind(), err)
+		}
+	}
+}
+
+func TestSetContainerImageValidated(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		for _, img := range []string{"", "Invalid/Image:1.0", "gcr.io/v2-namespace/hello-world:1.1.1:extra"} {
+			if err := gr.SetContainerImageValidated(0, img); err == nil {
+				t.Errorf("%s: expected error for %q", gr.Kind(), img)
+			}
+			if gr.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.1" {
+				t.Errorf("%s: image must not change for %q", gr.Kind(), img)
+			}
+		}
+
+		if err := gr.SetContainerImageValidated(1, "gcr.io/v2-namespace/hello-world:1.1.2"); !errors.Is(err, ErrContainerIndexOutOfRange) {
+			t.Errorf("%s: expected out of range error, got %v", gr.Kind(), err)
+		}
+
+		if err := gr.SetContainerImageValidated(0, "gcr.io/v2-namespace/hello-world:1.1.2"); err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if gr.Containers()[0].Image != "gcr.io/v2-namespace/hello-world:1.1.2" {
+			t.Errorf("%s: unexpected image: %s", gr.Kind(), gr.Containers()[0].Image)
+		}
+	}
+}
+
+func TestLastAppliedConfig(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if _, ok := gr.LastAppliedConfig(); ok {
+			t.Errorf("%s: expected no last applied configuration", gr.Kind())
+		}
+
+		gr.SetAnnotations(map[string]string{
+			types.KubernetesLastAppliedConfigAnnotation: `{"kind":"Deployment"}`,
+			"team": "platform",
+		})
+		if config, ok := gr.LastAppliedConfig(); !ok || config != `{"kind":"Deployment"}` {
+			t.Errorf("%s: unexpected last applied configuration: %s", gr.Kind(), config)
+		}
+
+		gr.ClearLastAppliedConfig()
+		if _, ok := gr.LastAppliedConfig(); ok {
+			t.Errorf("%s: expected last applied configuration to be removed", gr.Kind())
+		}
+		if gr.GetAnnotations()["team"] != "platform" {
+			t.Errorf("%s: other annotations must be preserved", gr.Kind())
+		}
+	}
+}
+
+func TestNextNumericTagContainers(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "myorg/app:41"},
+				{Name: "worker", Image: "myorg/worker:100"},
+				{Name: "web", Image: "nginx:1.2"},
+				{Name: "latest", Image: "myorg/app"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/myorg/migrate:7"},
+			},
+		},
+	})
+
+	latest := map[string]int{
+		"docker.io/myorg/app":    42,
+		"docker.io/myorg/worker": 100,
+		"gcr.io/myorg/migrate":   8,
+	}
+	current := func(repo string) int {
+		return latest[repo]
+	}
+
+	for _, gr := range resources {
+		expected := []ContainerRef{
+			{Name: "app", Image: "myorg/app:41", Index: 0},
+			{Name: "init", Image: "gcr.io/myorg/migrate:7", Index: 0, Init: true},
+		}
+		if got := gr.NextNumericTagContainers(current); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected containers: %+v", gr.Kind(), got)
+		}
+	}
+}
+
+func TestManifestListFlag(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if _, ok := gr.GetManifestListFlag("app"); ok {
+			t.Errorf("%s: expected no flag", gr.Kind())
+		}
+
+		for _, isList := range []bool{true, false} {
+			gr.SetManifestListFlag("app", isList)
+			got, ok := gr.GetManifestListFlag("app")
+			if !ok || got != isList {
+				t.Errorf("%s: expected %t, got %t (ok: %t)", gr.Kind(), isList, got, ok)
+			}
+		}
+
+		tests := map[string]struct {
+			want bool
+			ok   bool
+		}{
+			"TRUE":  {want: true, ok: true},
+			" 1 ":   {want: true, ok: true},
+			"False": {want: false, ok: true},
+			"yes":   {want: false, ok: false},
+			"":      {want: false, ok: false},
+		}
+		for value, tt := range tests {
+			gr.SetAnnotations(map[string]string{types.KeelManifestListAnnotationPrefix + "app": value})
+			got, ok := gr.GetManifestListFlag("app")
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("%s: %q: expected %t (ok: %t), got %t (ok: %t)", gr.Kind(), value, tt.want, tt.ok, got, ok)
+			}
+		}
+
+		if _, ok := gr.getPodTemplate().Annotations[types.KeelManifestListAnnotationPrefix+"app"]; ok {
+			t.Errorf("%s: flag must not be set on the pod template", gr.Kind())
+		}
+	}
+}
+
+func TestDryRunUpdate(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		patch, err := gr.DryRunUpdate(map[string]string{
+			"app":  "gcr.io/v2-namespace/hello-world:1.1.2",
+			"init": "karolisr/keel:0.3.0",
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		for _, img := range []string{"gcr.io/v2-namespace/hello-world:1.1.2", "karolisr/keel:0.3.0"} {
+			if !strings.Contains(string(patch), img) {
+				t.Errorf("%s: expected patch to contain %s, got %s", gr.Kind(), img, patch)
+			}
+		}
+		if strings.Contains(string(patch), "1.1.1") || strings.Contains(string(patch), "0.2.0") {
+			t.Errorf("%s: patch must not contain current images: %s", gr.Kind(), patch)
+		}
+		if gr.GetImages()[0] != "gcr.io/v2-namespace/hello-world:1.1.1" || gr.GetInitImages()[0] != "karolisr/keel:0.2.0" {
+			t.Errorf("%s: resource must not be changed", gr.Kind())
+		}
+
+		if _, err := gr.DryRunUpdate(map[string]string{"missing": "nginx:1.3"}); !errors.Is(err, ErrContainerNotFound) {
+			t.Errorf("%s: expected container not found error, got %v", gr.Kind(), err)
+		}
+	}
+}
+
+func TestRolloutPhase(t *testing.T) {
+	started := time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if _, _, ok := gr.GetRolloutPhase(); ok {
+			t.Errorf("%s: expected no rollout phase", gr.Kind())
+		}
+
+		gr.SetRolloutPhase(RolloutPhasePulling, started)
+		gr.SetRolloutPhase(RolloutPhaseReady, started.Add(time.Minute))
+
+		phase, when, ok := gr.GetRolloutPhase()
+		if !ok || phase != RolloutPhaseReady || !when.Equal(started.Add(time.Minute)) {
+			t.Errorf("%s: unexpected rollout phase: %s at %s", gr.Kind(), phase, when)
+		}
+		if _, ok := gr.getPodTemplate().Annotations[types.KeelRolloutPhaseAnnotation]; ok {
+			t.Errorf("%s: rollout phase must not be set on the pod template", gr.Kind())
+		}
+
+		gr.SetAnnotations(map[string]string{types.KeelRolloutPhaseAnnotation: "ready"})
+		if _, _, ok := gr.GetRolloutPhase(); ok {
+			t.Errorf("%s: expected malformed rollout phase to be ignored", gr.Kind())
+		}
+	}
+}
+
+func TestContainerDigest(t *testing.T) {
+	digest := "sha256:0d4f2f9f1a4e6c1d0f6d9c3e5d7cd6a5d1bd9f8e1f1e4a7e0c6b3a2b1c0d9e8f"
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "pinned", Image: "karolisr/keel@" + digest},
+				{Name: "tagged", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/hello-world:1.1.1@" + digest},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		for _, name := range []string{"pinned", "init"} {
+			if got, ok := gr.ContainerDigest(name); !ok || got != digest {
+				t.Errorf("%s: %s: unexpected digest: %s", gr.Kind(), name, got)
+			}
+		}
+		for _, name := range []string{"tagged", "missing"} {
+			if got, ok := gr.ContainerDigest(name); ok {
+				t.Errorf("%s: %s: expected no digest, got %s", gr.Kind(), name, got)
+			}
+		}
+	}
+}
+
+func TestContainerNames(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "web", Image: "nginx:1.2"},
+				{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "migrate", Image: "karolisr/keel:0.2.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		expected := []string{"web", "app", "init:migrate"}
+		if got := gr.ContainerNames(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: unexpected names: %v", gr.Kind(), got)
+		}
+
+		_, err := gr.DryRunUpdate(map[string]string{"api": "nginx:1.3"})
+		if err == nil || !strings.Contains(err.Error(), "available containers: web, app, init:migrate") {
+			t.Errorf("%s: expected error to list available containers, got %v", gr.Kind(), err)
+		}
+
+		// listed names can be passed back
+		for _, name := range gr.ContainerNames() {
+			if _, ok := gr.GetImageID(name); !ok {
+				t.Errorf("%s: container %s not found", gr.Kind(), name)
+			}
+		}
+		changed, err := gr.ForceUpdateImages(map[string]string{"init:migrate": "karolisr/keel:0.3.0"}, time.Now())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", gr.Kind(), err)
+		}
+		if !reflect.DeepEqual(changed, []string{"migrate"}) || gr.GetInitImages()[0] != "karolisr/keel:0.3.0" {
+			t.Errorf("%s: expected init container to be updated, got %v", gr.Kind(), changed)
+		}
+		if _, ok := gr.GetImageID("init:web"); ok {
+			t.Errorf("%s: init: prefix must only match init containers", gr.Kind())
+		}
+
+		_, err = gr.ForceUpdateImages(map[string]string{
+			"migrate":      "karolisr/keel:0.4.0",
+			"init:migrate": "karolisr/keel:0.5.0",
+		}, time.Now())
+		if err == nil || !strings.Contains(err.Error(), "refer to the same container") {
+			t.Errorf("%s: expected error for names referring to the same container, got %v", gr.Kind(), err)
+		}
+		if gr.GetInitImages()[0] != "karolisr/keel:0.3.0" {
+			t.Errorf("%s: resource must not change on error", gr.Kind())
+		}
+	}
+}
+
+func TestChangeCause(t *testing.T) {
+	for _, gr := range newTestResources(t, core_v1.PodTemplateSpec{}) {
+		if _, ok := gr.GetChangeCause(); ok {
+			t.Errorf("%s: expected no change cause", gr.Kind())
+		}
+
+		gr.SetChangeCause("keel automated update, version 1.1.1 -> 1.1.2")
+
+		if cause, ok := gr.GetChangeCause(); !ok || cause != "keel automated update, version 1.1.1 -> 1.1.2" {
+			t.Errorf("%s: unexpected change cause: %s", gr.Kind(), cause)
+		}
+		if _, ok := gr.getPodTemplate().Annotations[types.KubernetesChangeCauseAnnotation]; !ok {
+			t.Errorf("%s: expected change cause on the pod template", gr.Kind())
+		}
+		if _, ok := gr.GetAnnotations()[types.KubernetesChangeCauseAnnotation]; ok {
+			t.Errorf("%s: change cause must not be set on the workload", gr.Kind())
+		}
+	}
+
+	cj := newTestResources(t, core_v1.PodTemplateSpec{})[3]
+	cj.SetChangeCause("manual")
+	obj := cj.obj.(*batch_v1.CronJob)
+	if obj.Spec.JobTemplate.Spec.Template.Annotations[types.KubernetesChangeCauseAnnotation] != "manual" {
+		t.Errorf("expected change cause on the cronjob job template")
+	}
+}
@@ -0,0 +1,1156 @@
+package k8s
+
+import (
+	"sync"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8s_types "k8s.io/apimachinery/pkg/types"
+)
+
+// SafeResource - GenericResource that can be shared between goroutines. Every method of
+// GenericResource is overridden: mutating methods are guarded by a write lock, read
+// methods by a read lock and return copies so callers don't hold references into the
+// live object. Callbacks passed to the methods are called with the lock held and must
+// not call back into the resource.
+type SafeResource struct {
+	*GenericResource
+
+	mu sync.RWMutex
+}
+
+// NewSafeResource - wraps generic resource
+func NewSafeResource(gr *GenericResource) *SafeResource {
+	return &SafeResource{GenericResource: gr}
+}
+
+// DeepCopy returns an unguarded copy of the underlying resource
+func (r *SafeResource) DeepCopy() *GenericResource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.DeepCopy()
+}
+
+func (r *SafeResource) String() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.String()
+}
+
+// GetLabels - get a copy of resource labels
+func (r *SafeResource) GetLabels() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyMap(r.GenericResource.GetLabels())
+}
+
+// SetLabels - set resource labels
+func (r *SafeResource) SetLabels(labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetLabels(labels)
+}
+
+// MergeLabels - adds or overwrites provided labels
+func (r *SafeResource) MergeLabels(add map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.MergeLabels(add)
+}
+
+// GetAnnotations - get a copy of resource annotations
+func (r *SafeResource) GetAnnotations() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyMap(r.GenericResource.GetAnnotations())
+}
+
+// SetAnnotations - set resource annotations
+func (r *SafeResource) SetAnnotations(annotations map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetAnnotations(annotations)
+}
+
+// MergeAnnotations - adds or overwrites provided annotations
+func (r *SafeResource) MergeAnnotations(add map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.MergeAnnotations(add)
+}
+
+// GetSpecAnnotations - get a copy of resource spec template annotations
+func (r *SafeResource) GetSpecAnnotations() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyMap(r.GenericResource.GetSpecAnnotations())
+}
+
+// SetSpecAnnotations - set resource spec template annotations
+func (r *SafeResource) SetSpecAnnotations(annotations map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetSpecAnnotations(annotations)
+}
+
+// SetPodTemplateLabels - set pod template labels
+func (r *SafeResource) SetPodTemplateLabels(labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetPodTemplateLabels(labels)
+}
+
+// GetImages - returns images used by this resource
+func (r *SafeResource) GetImages() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetImages()
+}
+
+// GetInitImages - returns init images used by this resource
+func (r *SafeResource) GetInitImages() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetInitImages()
+}
+
+// Containers - returns a copy of containers managed by this resource
+func (r *SafeResource) Containers() []core_v1.Container {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyContainers(r.GenericResource.Containers())
+}
+
+// InitContainers - returns a copy of init containers managed by this resource
+func (r *SafeResource) InitContainers() []core_v1.Container {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyContainers(r.GenericResource.InitContainers())
+}
+
+// UpdateContainer - updates container image
+func (r *SafeResource) UpdateContainer(index int, image string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.UpdateContainer(index, image)
+}
+
+// UpdateInitContainer - updates init container image
+func (r *SafeResource) UpdateInitContainer(index int, image string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.UpdateInitContainer(index, image)
+}
+
+// SetContainerImageIfChanged - updates container image only when it differs from the current one
+func (r *SafeResource) SetContainerImageIfChanged(index int, image string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.SetContainerImageIfChanged(index, image)
+}
+
+// SetImagePullSecrets - replaces image pull secrets of the pod spec
+func (r *SafeResource) SetImagePullSecrets(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetImagePullSecrets(names)
+}
+
+// AddImagePullSecret - adds image pull secret to the pod spec
+func (r *SafeResource) AddImagePullSecret(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.AddImagePullSecret(name)
+}
+
+// StampUpdateTime - sets update time annotation on the pod template
+func (r *SafeResource) StampUpdateTime(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.StampUpdateTime(t)
+}
+
+// Touch - sets restartedAt annotation on the pod template
+func (r *SafeResource) Touch(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.Touch(t)
+}
+
+// GetStatus - returns resource status
+func (r *SafeResource) GetStatus() Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetStatus()
+}
+
+// EnsurePullAlwaysForTags - sets Always pull policy on regular and init containers
+// running one of the tags (i.e. mutable tags such as latest) so nodes re-pull the
+// image, returns number of updated containers
+func (r *SafeResource) EnsurePullAlwaysForTags(tags []string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.EnsurePullAlwaysForTags(tags)
+}
+
+// SetPullFailure - records image pull failure of the named container observed at t in
+// the workload annotations
+func (r *SafeResource) SetPullFailure(containerName, reason string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetPullFailure(containerName, reason, t)
+}
+
+// GetPullFailure - returns recorded image pull failure of the named container, false
+// when none was recorded or it can't be decoded
+func (r *SafeResource) GetPullFailure(containerName string) (reason string, when time.Time, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetPullFailure(containerName)
+}
+
+// ForceUpdateImage - sets image of the container at index and always stamps update time
+// on the pod template, so the workload is rolled even when the image is unchanged
+func (r *SafeResource) ForceUpdateImage(index int, image string, t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.ForceUpdateImage(index, image, t)
+}
+
+// SetResolvedDigests - records digests (container name -> digest) in a single
+// annotation, empty map removes the annotation
+func (r *SafeResource) SetResolvedDigests(digests map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetResolvedDigests(digests)
+}
+
+// GetResolvedDigests - returns recorded digests keyed by container name, empty map when
+// none were recorded or the annotation can't be decoded
+func (r *SafeResource) GetResolvedDigests() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetResolvedDigests()
+}
+
+// EnsureLabel - sets the label when it's missing or has a different value, returns
+// whether the resource was changed
+func (r *SafeResource) EnsureLabel(key, value string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.EnsureLabel(key, value)
+}
+
+// RemoveLabel - removes the label, returns whether the resource was changed
+func (r *SafeResource) RemoveLabel(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.RemoveLabel(key)
+}
+
+// RebaseOnto - replaces the receiver's object with a copy of the live resource with only
+// the images the receiver changed since it was read applied by container name
+func (r *SafeResource) RebaseOnto(live *GenericResource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.RebaseOnto(live)
+}
+
+// ForceUpdateImages - sets images of the named regular and init containers (container
+// name -> image) and stamps update time on the pod template once, so the workload is
+// rolled even when no image changed
+func (r *SafeResource) ForceUpdateImages(updates map[string]string, t time.Time) (changed []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.ForceUpdateImages(updates, t)
+}
+
+// SetContainerResources - sets resource requests and limits of the named regular or
+// init container, returns an error when the container isn't found
+func (r *SafeResource) SetContainerResources(name string, req core_v1.ResourceRequirements) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.SetContainerResources(name, req)
+}
+
+// SetContainerImageValidated - sets image of the container at index, the image is
+// rejected without changing the resource when it's not a valid image reference
+func (r *SafeResource) SetContainerImageValidated(index int, img string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.SetContainerImageValidated(index, img)
+}
+
+// LastAppliedConfig - returns configuration recorded by kubectl apply
+func (r *SafeResource) LastAppliedConfig() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.LastAppliedConfig()
+}
+
+// ClearLastAppliedConfig - removes configuration recorded by kubectl apply
+func (r *SafeResource) ClearLastAppliedConfig() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.ClearLastAppliedConfig()
+}
+
+// SetManifestListFlag - records whether image tag of the named container is a manifest
+// list
+func (r *SafeResource) SetManifestListFlag(containerName string, isList bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetManifestListFlag(containerName, isList)
+}
+
+// GetManifestListFlag - returns whether image tag of the named container was recorded
+// as a manifest list, false when nothing was recorded or the value can't be parsed
+func (r *SafeResource) GetManifestListFlag(containerName string) (isList bool, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetManifestListFlag(containerName)
+}
+
+// SetRolloutPhase - records rollout phase (one of RolloutPhasePulling,
+// RolloutPhaseStarting, RolloutPhaseReady or RolloutPhaseFailed) entered at t in the
+// workload annotations
+func (r *SafeResource) SetRolloutPhase(phase string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetRolloutPhase(phase, t)
+}
+
+// GetRolloutPhase - returns recorded rollout phase and the time it was entered, false
+// when none was recorded or it can't be decoded
+func (r *SafeResource) GetRolloutPhase() (phase string, when time.Time, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetRolloutPhase()
+}
+
+// SetChangeCause - sets kubernetes.io/change-cause annotation on the pod template
+func (r *SafeResource) SetChangeCause(cause string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetChangeCause(cause)
+}
+
+// GetChangeCause - returns kubernetes.io/change-cause annotation of the pod template
+func (r *SafeResource) GetChangeCause() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetChangeCause()
+}
+
+// DiffFromManifest - reports how the resource drifted from the manifest (i.e. last
+// applied manifest stored in Git)
+func (r *SafeResource) DiffFromManifest(manifest []byte) ([]FieldDiff, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.DiffFromManifest(manifest)
+}
+
+// HashPodTemplate - returns hash of the whole pod template, including annotations set
+// by Keel itself
+func (r *SafeResource) HashPodTemplate() (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.HashPodTemplate()
+}
+
+// StableSpecHash - returns hash of the pod template with keel.sh/* and rollout
+// annotations stripped, so it only changes when the spec is changed by someone else than
+// Keel
+func (r *SafeResource) StableSpecHash() (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.StableSpecHash()
+}
+
+// AppendImageHistory - records image change in the keel.sh/history annotation, only the
+// last MaxImageHistory entries are kept
+func (r *SafeResource) AppendImageHistory(container, oldImage, newImage string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.AppendImageHistory(container, oldImage, newImage, t)
+}
+
+// GetImageHistory - returns recorded image changes, oldest first
+func (r *SafeResource) GetImageHistory() ([]HistoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetImageHistory()
+}
+
+// GetIdentifier returns resource identifier
+func (r *SafeResource) GetIdentifier() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetIdentifier()
+}
+
+// GetName returns resource name
+func (r *SafeResource) GetName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetName()
+}
+
+// GetNamespace returns resource namespace
+func (r *SafeResource) GetNamespace() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetNamespace()
+}
+
+// Kind returns a type of resource that this structure represents
+func (r *SafeResource) Kind() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.Kind()
+}
+
+// GVK returns group, version and kind of the resource
+func (r *SafeResource) GVK() schema.GroupVersionKind {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GVK()
+}
+
+// IsDeployment - returns true when resource is a deployment
+func (r *SafeResource) IsDeployment() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsDeployment()
+}
+
+// IsStatefulSet - returns true when resource is a statefulset
+func (r *SafeResource) IsStatefulSet() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsStatefulSet()
+}
+
+// IsDaemonSet - returns true when resource is a daemonset
+func (r *SafeResource) IsDaemonSet() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsDaemonSet()
+}
+
+// IsCronJob - returns true when resource is a cronjob
+func (r *SafeResource) IsCronJob() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsCronJob()
+}
+
+// GetResource - get a copy of the resource
+func (r *SafeResource) GetResource() interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.DeepCopy().GetResource()
+}
+
+// RuntimeObject - returns a copy of the resource as a runtime object, false when it's not set
+func (r *SafeResource) RuntimeObject() (runtime.Object, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.DeepCopy().RuntimeObject()
+}
+
+// GetImagePullSecrets - returns secrets from pod spec
+func (r *SafeResource) GetImagePullSecrets() (secrets []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetImagePullSecrets()
+}
+
+// GetActiveDeadlineSeconds - returns the job active deadline, only applicable to kinds
+// that run jobs (cronjobs), nil for others
+func (r *SafeResource) GetActiveDeadlineSeconds() *int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyInt64(r.GenericResource.GetActiveDeadlineSeconds())
+}
+
+// MergePatchFrom - computes a strategic merge patch that turns original into the
+// receiver, both resources must be of the same kind
+func (r *SafeResource) MergePatchFrom(original *GenericResource) ([]byte, k8s_types.PatchType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.MergePatchFrom(original)
+}
+
+// GetImageID - returns image reference of the named container
+func (r *SafeResource) GetImageID(containerName string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetImageID(containerName)
+}
+
+// GetLabelsWithPrefix - returns resource labels which keys start with the prefix
+func (r *SafeResource) GetLabelsWithPrefix(prefix string) map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetLabelsWithPrefix(prefix)
+}
+
+// GetAnnotationsWithPrefix - returns resource annotations which keys start with the
+// prefix
+func (r *SafeResource) GetAnnotationsWithPrefix(prefix string) map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetAnnotationsWithPrefix(prefix)
+}
+
+// GetPodTemplateLabels - get a copy of pod template labels
+func (r *SafeResource) GetPodTemplateLabels() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyMap(r.GenericResource.GetPodTemplateLabels())
+}
+
+// GetTerminationGracePeriodSeconds - returns pod termination grace period, nil when
+// it's not set (Kubernetes defaults to 30 seconds)
+func (r *SafeResource) GetTerminationGracePeriodSeconds() *int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyInt64(r.GenericResource.GetTerminationGracePeriodSeconds())
+}
+
+// GetContainerResources - returns a copy of the named container resource requirements
+func (r *SafeResource) GetContainerResources(name string) (core_v1.ResourceRequirements, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetContainerResources(name)
+}
+
+// ImageInventory - returns images grouped with their container names, regular
+// containers come first (in spec order), then init containers
+func (r *SafeResource) ImageInventory() []ContainerImage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ImageInventory()
+}
+
+// IsPaused - returns true when deployment is paused, updates to paused deployments
+// won't be rolled out until they are resumed
+func (r *SafeResource) IsPaused() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsPaused()
+}
+
+// GetContainerEnvValue - returns literal value of the env variable of the named
+// container, variables referencing other sources (valueFrom) are not returned
+func (r *SafeResource) GetContainerEnvValue(containerName, key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetContainerEnvValue(containerName, key)
+}
+
+// LastUpdateTime - returns update time stamped on the pod template
+func (r *SafeResource) LastUpdateTime() (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.LastUpdateTime()
+}
+
+// GetContainerWorkingDir - returns working directory override of the named container,
+// empty string when it's not set
+func (r *SafeResource) GetContainerWorkingDir(name string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetContainerWorkingDir(name)
+}
+
+// Validate - checks whether resource is internally consistent: it has at least one
+// container, every container has a name and an image and the selector is set (where
+// applicable)
+func (r *SafeResource) Validate() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.Validate()
+}
+
+// GetReadinessGates - returns a copy of the pod readiness gates
+func (r *SafeResource) GetReadinessGates() []core_v1.PodReadinessGate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetReadinessGates()
+}
+
+// EstimatedReadyTimeout - estimates how long it can take for the pods of this resource
+// to become ready, based on startup and readiness probes of the slowest container plus
+// MinReadySeconds
+func (r *SafeResource) EstimatedReadyTimeout() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.EstimatedReadyTimeout()
+}
+
+// GetRevisionHistoryLimit - returns revision history limit, nil when it's not set
+// (Kubernetes defaults to 10) or not applicable (cronjobs)
+func (r *SafeResource) GetRevisionHistoryLimit() *int32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyInt32(r.GenericResource.GetRevisionHistoryLimit())
+}
+
+// GetLifecycle - returns a copy of the named container lifecycle hooks, nil when
+// container has no hooks
+func (r *SafeResource) GetLifecycle(containerName string) (*core_v1.Lifecycle, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetLifecycle(containerName)
+}
+
+// MergeAnnotationsFrom - copies annotations set on the latest version of the resource
+// (i.e. by other controllers) which are missing on the receiver
+func (r *SafeResource) MergeAnnotationsFrom(latest *GenericResource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.MergeAnnotationsFrom(latest)
+}
+
+// MergePodTemplateAnnotationsFrom - same as MergeAnnotationsFrom, but for pod template
+// annotations
+func (r *SafeResource) MergePodTemplateAnnotationsFrom(latest *GenericResource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.MergePodTemplateAnnotationsFrom(latest)
+}
+
+// ImagesByRegistry - returns repositories of regular and init containers grouped by
+// their registry host
+func (r *SafeResource) ImagesByRegistry() map[string][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ImagesByRegistry()
+}
+
+// NeedsUpdate - returns true when any of the containers (regular or init) runs a
+// different image than desired, desired maps container names to images
+func (r *SafeResource) NeedsUpdate(desired map[string]string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.NeedsUpdate(desired)
+}
+
+// ScaleTargetRef - returns identity of the resource as it would be referenced by a
+// HorizontalPodAutoscaler scale target, ok is false for kinds that can't be scaled
+func (r *SafeResource) ScaleTargetRef() (apiVersion, kind, name string, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ScaleTargetRef()
+}
+
+// PullSecretsForImage - returns names of the pull secrets that could authenticate the
+// image
+func (r *SafeResource) PullSecretsForImage(img string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.PullSecretsForImage(img)
+}
+
+// GetNormalizedImages - returns fully qualified images of regular and init containers
+// (i.e. nginx becomes docker.io/library/nginx:latest), images which can't be parsed are
+// returned unchanged
+func (r *SafeResource) GetNormalizedImages() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetNormalizedImages()
+}
+
+// SetUpdateSource - records which trigger (polling, webhook, approval) caused the
+// update
+func (r *SafeResource) SetUpdateSource(source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetUpdateSource(source)
+}
+
+// GetUpdateSource - returns trigger that caused the last update
+func (r *SafeResource) GetUpdateSource() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetUpdateSource()
+}
+
+// GetContainerTag - returns tag of the named container image ignoring digest, latest
+// when the image has no tag and empty string for images pinned by digest only
+func (r *SafeResource) GetContainerTag(containerName string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetContainerTag(containerName)
+}
+
+// GetTopologySpreadConstraints - returns a copy of the pod topology spread constraints
+func (r *SafeResource) GetTopologySpreadConstraints() []core_v1.TopologySpreadConstraint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetTopologySpreadConstraints()
+}
+
+// Report - returns a report of the resource, it doesn't reference the resource
+func (r *SafeResource) Report() ResourceReport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.Report()
+}
+
+// DesiredPods - returns desired number of pods: spec replicas for deployments and
+// statefulsets (1 when not set), desired number of scheduled pods for daemonsets and 0
+// for cronjobs
+func (r *SafeResource) DesiredPods() int32 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.DesiredPods()
+}
+
+// InitContainerOrder - returns init container names in the order they are executed
+func (r *SafeResource) InitContainerOrder() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.InitContainerOrder()
+}
+
+// CloneToNamespace - returns a copy of the resource in another namespace, resource
+// version and UID are cleared so the copy can be created
+func (r *SafeResource) CloneToNamespace(ns string) *GenericResource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.CloneToNamespace(ns)
+}
+
+// Repositories - returns sorted fully qualified repositories (without tags or digests)
+// used by regular and init containers
+func (r *SafeResource) Repositories() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.Repositories()
+}
+
+// SetTrackedDigest - records the digest the named container image tag resolved to
+func (r *SafeResource) SetTrackedDigest(containerName, digest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetTrackedDigest(containerName, digest)
+}
+
+// GetTrackedDigest - returns the recorded digest of the named container
+func (r *SafeResource) GetTrackedDigest(containerName string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetTrackedDigest(containerName)
+}
+
+// ContainersWithStaleDigest - returns names of the containers (regular and init)
+// running the tag whose recorded digest differs from the current one
+func (r *SafeResource) ContainersWithStaleDigest(tag, currentDigest string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainersWithStaleDigest(tag, currentDigest)
+}
+
+// EffectivePullPolicy - returns pull policy of the named container, when it's not set
+// Kubernetes defaults to Always for the latest tag and IfNotPresent otherwise
+func (r *SafeResource) EffectivePullPolicy(containerName string) (core_v1.PullPolicy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.EffectivePullPolicy(containerName)
+}
+
+// IsContainerDigestPinned - returns true when the named container image is pinned by
+// digest (i.e. nginx@sha256:...), second value is false when container isn't found
+func (r *SafeResource) IsContainerDigestPinned(name string) (bool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsContainerDigestPinned(name)
+}
+
+// ImageUsageCounts - returns how many containers (regular and init) use each image,
+// images are normalized so nginx and docker.io/library/nginx:latest are counted
+// together
+func (r *SafeResource) ImageUsageCounts() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ImageUsageCounts()
+}
+
+// PolicyAnnotation - returns policy set in the keel.sh/policy annotation
+func (r *SafeResource) PolicyAnnotation() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.PolicyAnnotation()
+}
+
+// SetPolicy - sets keel.sh/policy annotation
+func (r *SafeResource) SetPolicy(policy string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.SetPolicy(policy)
+}
+
+// ClearPolicy - removes keel.sh/policy annotation, keel.sh/policy label (possibly owned
+// by other tooling) is left untouched
+func (r *SafeResource) ClearPolicy() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.ClearPolicy()
+}
+
+// AggregateResources - returns effective pod requests and limits: sum of regular
+// containers or the largest init container, whichever is higher (init containers run
+// one at a time)
+func (r *SafeResource) AggregateResources() (requests, limits core_v1.ResourceList) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.AggregateResources()
+}
+
+// HasFinalizer - returns true when resource has the finalizer
+func (r *SafeResource) HasFinalizer(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.HasFinalizer(name)
+}
+
+// AddFinalizer - adds finalizer unless resource already has it
+func (r *SafeResource) AddFinalizer(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.AddFinalizer(name)
+}
+
+// RemoveFinalizer - removes finalizer, it's a no-op if resource doesn't have it
+func (r *SafeResource) RemoveFinalizer(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.GenericResource.RemoveFinalizer(name)
+}
+
+// TargetArchitecture - returns target architecture set in the keel.sh/arch annotation
+func (r *SafeResource) TargetArchitecture() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.TargetArchitecture()
+}
+
+// GetPullHint - returns expected image pull duration of the named container, false when
+// the annotation is not set or can't be parsed
+func (r *SafeResource) GetPullHint(containerName string) (time.Duration, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetPullHint(containerName)
+}
+
+// ContainersSorted - returns a copy of regular containers sorted by name, use
+// Containers() for the spec order
+func (r *SafeResource) ContainersSorted() []core_v1.Container {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainersSorted()
+}
+
+// PrimaryImage - returns image of the first regular container, false when resource has
+// no containers
+func (r *SafeResource) PrimaryImage() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.PrimaryImage()
+}
+
+// RebaseImages - replaces oldRepoPrefix with newRepoPrefix in repositories of regular
+// and init containers preserving their tags and digests, returns number of updated
+// containers
+func (r *SafeResource) RebaseImages(oldRepoPrefix, newRepoPrefix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.RebaseImages(oldRepoPrefix, newRepoPrefix)
+}
+
+// PodTemplateLabelValue - returns value of the pod template label
+func (r *SafeResource) PodTemplateLabelValue(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.PodTemplateLabelValue(key)
+}
+
+// ValidateImageUpdate - guards against updates pointing the container at a different
+// repository (unless allowed with the keel.sh/allowRepositoryChange annotation) or
+// downgrading it across a major version
+func (r *SafeResource) ValidateImageUpdate(index int, newImage string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ValidateImageUpdate(index, newImage)
+}
+
+// PodTemplateHashLabel - returns pod-template-hash label from the pod template or the
+// selector
+func (r *SafeResource) PodTemplateHashLabel() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.PodTemplateHashLabel()
+}
+
+// EligibleContainers - returns regular and init containers for which the predicate
+// (i.e. policy check) returns true
+func (r *SafeResource) EligibleContainers(predicate func(containerName, image string) bool) []ContainerRef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.EligibleContainers(predicate)
+}
+
+// RolloutMessage - returns human readable rollout state
+func (r *SafeResource) RolloutMessage() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.RolloutMessage()
+}
+
+// OutdatedContainers - returns regular and init containers whose tag is older than the
+// latest tag supplied by the callback for their fully qualified repository (i.e.
+// docker.io/library/nginx)
+func (r *SafeResource) OutdatedContainers(latest func(repo string) (string, bool)) []OutdatedContainer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.OutdatedContainers(latest)
+}
+
+// ResolveUpdateTarget - picks the container to update for the repository when both
+// regular and init containers use it: the first matching init container when preferInit
+// is set, the first matching regular container otherwise
+func (r *SafeResource) ResolveUpdateTarget(repo string, preferInit bool) (ContainerRef, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ResolveUpdateTarget(repo, preferInit)
+}
+
+// NodeSelectorArchitecture - returns architecture the pods are scheduled to by the
+// kubernetes.io/arch (or legacy beta.kubernetes.io/arch) node selector
+func (r *SafeResource) NodeSelectorArchitecture() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.NodeSelectorArchitecture()
+}
+
+// ContainerTagsAreSemver - returns whether current tag of each regular and init
+// container is a valid semantic version, keyed by container name
+func (r *SafeResource) ContainerTagsAreSemver() map[string]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainerTagsAreSemver()
+}
+
+// IsSuspended - returns true when CronJob is suspended, other kinds are never suspended
+func (r *SafeResource) IsSuspended() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.IsSuspended()
+}
+
+// ContainerImageTagOnly - returns image of the named container with digest suffix
+// (@sha256:...) stripped, tag is preserved, false for images pinned by digest only
+func (r *SafeResource) ContainerImageTagOnly(containerName string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainerImageTagOnly(containerName)
+}
+
+// ParseImages - parses images of regular and init containers, images that can't be
+// parsed are returned as *ImageParseError instead of being skipped
+func (r *SafeResource) ParseImages() ([]ImageReference, []error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ParseImages()
+}
+
+// PreviewUpdate - renders proposed image updates (container name -> new image) as "web:
+// nginx:1.2 -> nginx:1.3" lines in container order
+func (r *SafeResource) PreviewUpdate(updates map[string]string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.PreviewUpdate(updates)
+}
+
+// CompareContainerTag - compares current tag of the named container to the candidate
+// tag, returns -1, 0 or 1 when current tag is lower, equal or higher
+func (r *SafeResource) CompareContainerTag(containerName, candidateTag string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.CompareContainerTag(containerName, candidateTag)
+}
+
+// ContainersMatchingTagGlob - returns regular and init containers which current tag
+// matches the glob pattern (same matching as glob policies), containers pinned by digest
+// only never match
+func (r *SafeResource) ContainersMatchingTagGlob(pattern string) ([]ContainerRef, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainersMatchingTagGlob(pattern)
+}
+
+// ExplainEligibility - explains whether container at index would be updated
+func (r *SafeResource) ExplainEligibility(index int, policy func(current string) (string, bool)) (update string, reason string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ExplainEligibility(index, policy)
+}
+
+// ContainerPolicies - returns effective policy of each regular and init container keyed
+// by container name
+func (r *SafeResource) ContainerPolicies() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainerPolicies()
+}
+
+// UsesRegistry - returns true when any regular or init container runs an image from the
+// registry host, Docker Hub can be given as docker.io or index.docker.io
+func (r *SafeResource) UsesRegistry(registry string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.UsesRegistry(registry)
+}
+
+// ContainerTagEquals - returns whether the named container runs the same repository and
+// tag as the image, digests are ignored
+func (r *SafeResource) ContainerTagEquals(containerName, img string) (equal bool, found bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainerTagEquals(containerName, img)
+}
+
+// ExternalURL - returns application URL set in the keel.sh/url annotation
+func (r *SafeResource) ExternalURL() (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ExternalURL()
+}
+
+// ImageDriftReport - returns images of regular and init containers with their recorded
+// digests, taken from the per-container digest annotation or the resolved digests
+// annotation, in container order
+func (r *SafeResource) ImageDriftReport() []ContainerDrift {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ImageDriftReport()
+}
+
+// FindContainersByImageSuffix - returns regular and init containers which normalized
+// repository (i.e. docker.io/library/nginx) equals the suffix or ends with "/" +
+// suffix, so matching is done on whole path segments: "myorg/app" matches
+// docker.io/myorg/app and gcr.io/myorg/app but not docker.io/othermyorg/app
+func (r *SafeResource) FindContainersByImageSuffix(suffix string) []ContainerRef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.FindContainersByImageSuffix(suffix)
+}
+
+// GetUniqueImages - returns sorted fully qualified images of regular and init
+// containers without duplicates
+func (r *SafeResource) GetUniqueImages() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.GetUniqueImages()
+}
+
+// NextNumericTagContainers - returns regular and init containers running a numeric tag
+// (i.e. build number) lower than the latest value reported by the callback for their
+// fully qualified repository (i.e. docker.io/myorg/app)
+func (r *SafeResource) NextNumericTagContainers(current func(repo string) int) []ContainerRef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.NextNumericTagContainers(current)
+}
+
+// DryRunUpdate - returns strategic merge patch the image updates (container name ->
+// image) would produce, the resource itself is not changed
+func (r *SafeResource) DryRunUpdate(updates map[string]string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.DryRunUpdate(updates)
+}
+
+// HasImage - returns true when any regular or init container runs the image, images are
+// compared in their fully qualified form (nginx equals docker.io/library/nginx:latest)
+func (r *SafeResource) HasImage(img string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.HasImage(img)
+}
+
+// HasImagePrefix - returns true when fully qualified image of any regular or init
+// container starts with the prefix (i.e. gcr.io/myorg/)
+func (r *SafeResource) HasImagePrefix(prefix string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.HasImagePrefix(prefix)
+}
+
+// ContainerDigest - returns digest (i.e. sha256:...) the named container image is
+// pinned to, false when the image is not pinned by digest or container isn't found
+func (r *SafeResource) ContainerDigest(containerName string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainerDigest(containerName)
+}
+
+// ContainerNames - returns names of regular containers followed by names of init
+// containers prefixed with "init:", both in spec order
+func (r *SafeResource) ContainerNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.ContainerNames()
+}
+
+// SnapshotImages - captures images of regular and init containers
+func (r *SafeResource) SnapshotImages() ImageSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.GenericResource.SnapshotImages()
+}
+
+// RestoreImages - reverts regular and init containers to the images recorded in the
+// snapshot
+func (r *SafeResource) RestoreImages(snapshot ImageSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.GenericResource.RestoreImages(snapshot)
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyInt64(v *int64) *int64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyInt32(v *int32) *int32 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyContainers(containers []core_v1.Container) []core_v1.Container {
+	var c []core_v1.Container
+	for _, container := range containers {
+		c = append(c, *container.DeepCopy())
+	}
+	return c
+}
@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestSafeResourceConcurrentAccess(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+		},
+	})
+
+	for _, gr := range resources {
+		sr := NewSafeResource(gr)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				sr.UpdateContainer(0, fmt.Sprintf("gcr.io/v2-namespace/hello-world:1.1.%d", i))
+				sr.MergeAnnotations(map[string]string{fmt.Sprintf("key-%d", i): "value"})
+			}(i)
+			go func() {
+				defer wg.Done()
+				_ = sr.GetImages()
+				_ = sr.GetAnnotations()
+				_ = sr.Containers()
+			}()
+		}
+		wg.Wait()
+
+		if annotations := sr.GetAnnotations(); len(annotations) != 10 {
+			t.Errorf("%s: unexpected annotations: %v", gr.Kind(), annotations)
+		}
+
+		labels := sr.GetLabels()
+		labels["changed"] = "true"
+		if _, ok := sr.GetLabels()["changed"]; ok {
+			t.Errorf("%s: expected labels to be copied", gr.Kind())
+		}
+	}
+}
+
+// TestSafeResourceWrapsAllMethods - methods promoted from the embedded GenericResource
+// bypass the lock, every method has to be overridden
+func TestSafeResourceWrapsAllMethods(t *testing.T) {
+	generic := reflect.TypeOf(&GenericResource{})
+	safe := reflect.TypeOf(&SafeResource{})
+
+	for i := 0; i < generic.NumMethod(); i++ {
+		name := generic.Method(i).Name
+		method, ok := safe.MethodByName(name)
+		if !ok {
+			t.Errorf("%s: method not found", name)
+			continue
+		}
+		// promoted methods are compiler generated wrappers
+		file, _ := runtime.FuncForPC(method.Func.Pointer()).FileLine(method.Func.Pointer())
+		if !strings.HasSuffix(file, "safe_resource.go") {
+			t.Errorf("%s: not overridden by SafeResource, calls bypass the lock", name)
+		}
+	}
+}
+
+func TestSafeResourceConcurrentRebase(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{{Name: "app", Image: "gcr.io/v2-namespace/hello-world:1.1.1"}},
+		},
+	})
+
+	for _, gr := range resources {
+		sr := NewSafeResource(gr)
+		live := gr.DeepCopy()
+		identifier := gr.Identifier
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				image := fmt.Sprintf("gcr.io/v2-namespace/hello-world:1.1.%d", i)
+				if _, err := sr.ForceUpdateImages(map[string]string{"app": image}, time.Now()); err != nil {
+					t.Errorf("%s: unexpected error: %s", gr.Kind(), err)
+				}
+				if err := sr.RebaseOnto(live); err != nil {
+					t.Errorf("%s: unexpected error: %s", gr.Kind(), err)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				// identity fields are read without the lock
+				if sr.Identifier != identifier {
+					t.Errorf("%s: unexpected identifier: %s", gr.Kind(), sr.Identifier)
+				}
+				_, _ = sr.GetContainerTag("app")
+				_, _ = sr.LastUpdateTime()
+			}()
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,82 @@
+package k8s
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ImageSnapshot - immutable record of resource images at a point in time,
+// used to detect whether resource changed since i.e. approval was requested
+type ImageSnapshot struct {
+	// Images sorted by container name, regular containers before init containers
+	// when names collide
+	Images []ContainerImage `json:"images"`
+	// Hash of the images
+	Hash string `json:"hash"`
+}
+
+// Equal returns true when both snapshots record the same images
+func (s ImageSnapshot) Equal(other ImageSnapshot) bool {
+	return s.Hash == other.Hash
+}
+
+// SnapshotImages - captures images of regular and init containers
+func (r *GenericResource) SnapshotImages() ImageSnapshot {
+	images := r.ImageInventory()
+	sort.SliceStable(images, func(i, j int) bool {
+		if images[i].Name != images[j].Name {
+			return images[i].Name < images[j].Name
+		}
+		return !images[i].Init && images[j].Init
+	})
+
+	h := sha256.New()
+	for _, img := range images {
+		fmt.Fprintf(h, "%s\x00%s\x00%t\n", img.Name, img.Image, img.Init)
+	}
+
+	return ImageSnapshot{
+		Images: images,
+		Hash:   hex.EncodeToString(h.Sum(nil)),
+	}
+}
+
+// RestoreImages - reverts regular and init containers to the images recorded in the
+// snapshot. Returns an error without changing the resource when any of the containers
+// in the snapshot no longer exists.
+func (r *GenericResource) RestoreImages(snapshot ImageSnapshot) error {
+	regular := make(map[string]int)
+	for idx, c := range r.Containers() {
+		regular[c.Name] = idx
+	}
+	initContainers := make(map[string]int)
+	for idx, c := range r.InitContainers() {
+		initContainers[c.Name] = idx
+	}
+
+	var missing []string
+	for _, img := range snapshot.Images {
+		indexes := regular
+		if img.Init {
+			indexes = initContainers
+		}
+		if _, ok := indexes[img.Name]; !ok {
+			missing = append(missing, img.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("containers not found: %s", strings.Join(missing, ", "))
+	}
+
+	for _, img := range snapshot.Images {
+		if img.Init {
+			r.UpdateInitContainer(initContainers[img.Name], img.Image)
+		} else {
+			r.UpdateContainer(regular[img.Name], img.Image)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"testing"
+
+	core_v1 "k8s.io/api/core/v1"
+)
+
+func TestSnapshotImages(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "web", Image: "gcr.io/v2-namespace/hello-world:1.1.1"},
+				{Name: "app", Image: "gcr.io/v2-namespace/app:1.0.0"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/init:1.0.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		snapshot := gr.SnapshotImages()
+
+		names := []string{}
+		for _, img := range snapshot.Images {
+			names = append(names, img.Name)
+		}
+		if len(names) != 3 || names[0] != "app" || names[1] != "init" || names[2] != "web" {
+			t.Errorf("%s: unexpected snapshot order: %v", gr.Kind(), names)
+		}
+
+		if !snapshot.Equal(gr.DeepCopy().SnapshotImages()) {
+			t.Errorf("%s: expected snapshots of a copy to be equal", gr.Kind())
+		}
+
+		gr.UpdateInitContainer(0, "gcr.io/v2-namespace/init:1.0.1")
+		if snapshot.Equal(gr.SnapshotImages()) {
+			t.Errorf("%s: expected snapshot to change after update", gr.Kind())
+		}
+	}
+}
+
+func TestRestoreImages(t *testing.T) {
+	resources := newTestResources(t, core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			Containers: []core_v1.Container{
+				{Name: "app", Image: "gcr.io/v2-namespace/app:1.0.0"},
+			},
+			InitContainers: []core_v1.Container{
+				{Name: "init", Image: "gcr.io/v2-namespace/init:1.0.0"},
+			},
+		},
+	})
+
+	for _, gr := range resources {
+		snapshot := gr.SnapshotImages()
+
+		gr.UpdateContainer(0, "gcr.io/v2-namespace/app:2.0.0")
+		gr.UpdateInitContainer(0, "gcr.io/v2-namespace/init:2.0.0")
+
+		if err := gr.RestoreImages(snapshot); err != nil {
+			t.Fatalf("%s: failed to restore images: %s", gr.Kind(), err)
+		}
+		if !snapshot.Equal(gr.SnapshotImages()) {
+			t.Errorf("%s: images weren't restored: %v", gr.Kind(), gr.ImageInventory())
+		}
+
+		gr.UpdateContainer(0, "gcr.io/v2-namespace/app:2.0.0")
+		snapshot.Images = append(snapshot.Images, ContainerImage{Name: "removed", Image: "gcr.io/v2-namespace/removed:1.0.0"})
+		if err := gr.RestoreImages(snapshot); err == nil {
+			t.Errorf("%s: expected an error for missing container", gr.Kind())
+		}
+		if img := gr.Containers()[0].Image; img != "gcr.io/v2-namespace/app:2.0.0" {
+			t.Errorf("%s: resource shouldn't change on mismatch: %s", gr.Kind(), img)
+		}
+	}
+}
@@ -33,12 +33,12 @@ func (np *NilPolicy) Type() PolicyType                       { return PolicyType
 // GetPolicyFromLabelsOrAnnotations - gets policy from k8s labels or annotations
 func GetPolicyFromLabelsOrAnnotations(labels map[string]string, annotations map[string]string) Policy {
 
-	policyNameA, ok := getPolicyFromLabels(annotations)
+	policyNameA, ok := GetPolicyFromLabels(annotations)
 	if ok {
 		return GetPolicy(policyNameA, &Options{MatchTag: getMatchTag(annotations), MatchPreRelease: getMatchPreRelease(annotations)})
 	}
 
-	policyNameL, ok := getPolicyFromLabels(labels)
+	policyNameL, ok := GetPolicyFromLabels(labels)
 	if !ok {
 		return &NilPolicy{}
 	}
@@ -110,7 +110,9 @@ func ParseSemverPolicy(policy string, matchPreRelease bool) Policy {
 	}
 }
 
-func getPolicyFromLabels(labels map[string]string) (string, bool) {
+// GetPolicyFromLabels - gets policy name from k8s labels or annotations, legacy
+// keel.observer/policy key is used when keel.sh/policy isn't set
+func GetPolicyFromLabels(labels map[string]string) (string, bool) {
 	policy, ok := labels[types.KeelPolicyLabel]
 	if ok {
 		return policy, true
@@ -38,12 +38,12 @@ func Test_getPolicyFromLabels(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1 := getPolicyFromLabels(tt.args.labels)
+			got, got1 := GetPolicyFromLabels(tt.args.labels)
 			if got != tt.want {
-				t.Errorf("getPolicyFromLabels() got = %v, want %v", got, tt.want)
+				t.Errorf("GetPolicyFromLabels() got = %v, want %v", got, tt.want)
 			}
 			if got1 != tt.want1 {
-				t.Errorf("getPolicyFromLabels() got1 = %v, want %v", got1, tt.want1)
+				t.Errorf("GetPolicyFromLabels() got1 = %v, want %v", got1, tt.want1)
 			}
 		})
 	}
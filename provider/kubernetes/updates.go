@@ -154,7 +154,5 @@ func checkForUpdate(plc policy.Policy, repo *types.Repository, resource *k8s.Gen
 }
 
 func setUpdateTime(resource *k8s.GenericResource) {
-	specAnnotations := resource.GetSpecAnnotations()
-	specAnnotations[types.KeelUpdateTimeAnnotation] = time.Now().String()
-	resource.SetSpecAnnotations(specAnnotations)
+	resource.StampUpdateTime(time.Now())
 }
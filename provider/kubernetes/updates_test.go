@@ -750,6 +750,8 @@ func TestProvider_checkForUpdate(t *testing.T) {
 				} else {
 					t.Errorf("Provider.checkUnversionedDeployment() missing types.KeelUpdateTimeAnnotation annotation")
 				}
+				// read again so images recorded at read time match the expected resource
+				gotUpdatePlan.Resource = MustParseGR(gotUpdatePlan.Resource.GetResource())
 			}
 
 			if !reflect.DeepEqual(gotUpdatePlan, tt.wantUpdatePlan) {
@@ -1235,6 +1237,8 @@ func TestProvider_checkForUpdateSemver(t *testing.T) {
 				} else {
 					t.Errorf("Provider.checkVersionedDeployment() missing types.KeelUpdateTimeAnnotation annotation")
 				}
+				// read again so images recorded at read time match the expected resource
+				gotUpdatePlan.Resource = MustParseGR(gotUpdatePlan.Resource.GetResource())
 			}
 
 			if !reflect.DeepEqual(gotUpdatePlan, tt.wantUpdatePlan) {
@@ -19,9 +19,19 @@ import (
 // KeelDefaultPort - default port for application
 const KeelDefaultPort = 9300
 
+// KeelAnnotationPrefix - prefix of all labels and annotations owned by Keel
+const KeelAnnotationPrefix = "keel.sh/"
+
 // KeelPolicyLabel - keel update policies (version checking)
 const KeelPolicyLabel = "keel.sh/policy"
 
+// KeelPolicyAnnotation - policy annotation, takes precedence over the label of the same key
+const KeelPolicyAnnotation = KeelPolicyLabel
+
+// KeelContainerPolicyAnnotationPrefix - policy override of a single container,
+// container name is appended to the prefix (i.e. keel.sh/policy.app=patch)
+const KeelContainerPolicyAnnotationPrefix = "keel.sh/policy."
+
 const KeelImagePullSecretAnnotation = "keel.sh/imagePullSecret"
 
 // KeelTriggerLabel - trigger label is used to specify custom trigger types
@@ -58,6 +68,49 @@ const KeelMinimumApprovalsLabel = "keel.sh/approvals"
 // KeelUpdateTimeAnnotation - update time
 const KeelUpdateTimeAnnotation = "keel.sh/update-time"
 
+// KeelUpdateSourceAnnotation - trigger that caused the last update (polling, webhook, approval)
+const KeelUpdateSourceAnnotation = "keel.sh/update-source"
+
+// KeelArchitectureAnnotation - target architecture of the workload (i.e. amd64, arm64)
+const KeelArchitectureAnnotation = "keel.sh/arch"
+
+// KeelPullHintAnnotationPrefix - expected image pull duration of a container,
+// container name is appended to the prefix (i.e. keel.sh/pull-hint.app=2m)
+const KeelPullHintAnnotationPrefix = "keel.sh/pull-hint."
+
+// KeelAllowRepositoryChangeAnnotation - allows updates to point containers to a different repository
+const KeelAllowRepositoryChangeAnnotation = "keel.sh/allowRepositoryChange"
+
+// KeelHistoryAnnotation - recent image changes of the workload
+const KeelHistoryAnnotation = "keel.sh/history"
+
+// KeelPullFailureAnnotationPrefix - image pull failure observed after the update,
+// container name is appended to the prefix (i.e. keel.sh/pull-failure.app)
+const KeelPullFailureAnnotationPrefix = "keel.sh/pull-failure."
+
+// KeelResolvedDigestsAnnotation - digests container image tags resolved to,
+// stored as a JSON object keyed by container name
+const KeelResolvedDigestsAnnotation = "keel.sh/resolved-digests"
+
+// KeelURLAnnotation - external URL of the application, included in notifications
+const KeelURLAnnotation = "keel.sh/url"
+
+// KeelManifestListAnnotationPrefix - whether container image tag is a manifest list,
+// container name is appended to the prefix (i.e. keel.sh/manifest-list.app=true)
+const KeelManifestListAnnotationPrefix = "keel.sh/manifest-list."
+
+// KeelRolloutPhaseAnnotation - current phase of the rollout started by Keel
+const KeelRolloutPhaseAnnotation = "keel.sh/rollout-phase"
+
+// KubernetesChangeCauseAnnotation - rollout history change cause
+const KubernetesChangeCauseAnnotation = "kubernetes.io/change-cause"
+
+// KubernetesRestartedAtAnnotation - pod template annotation set by kubectl rollout restart
+const KubernetesRestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// KubernetesLastAppliedConfigAnnotation - configuration recorded by kubectl apply
+const KubernetesLastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
 // KeelApprovalDeadlineLabel - approval deadline
 const KeelApprovalDeadlineLabel = "keel.sh/approvalDeadline"
 